@@ -1,14 +1,57 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
+)
+
+// 备份原因
+const (
+	backupReasonPreUpdate  = "pre-update"  // 更新 hosts 之前
+	backupReasonPreRestore = "pre-restore" // 恢复备份之前
+	backupReasonManual     = "manual"      // 手动创建
 )
 
+// backupTimeLayout 备份文件名中的时间格式
+const backupTimeLayout = "20060102_150405"
+
+// BackupMeta 备份元数据，保存在与备份同名的 .json 文件中
+type BackupMeta struct {
+	Hash        string    `json:"hash"` // 未压缩内容的 SHA-256
+	Reason      string    `json:"reason"`
+	Source      string    `json:"source,omitempty"` // 更新前备份时本次更新使用的数据源
+	ToolVersion string    `json:"toolVersion"`
+	EntryCount  int       `json:"entryCount"` // 管理区块中的记录数
+	Size        int64     `json:"size"`       // 未压缩大小
+	Created     time.Time `json:"created"`
+}
+
+// backupInfo 备份文件信息
+type backupInfo struct {
+	name string
+	time time.Time
+	size int64 // 备份文件在磁盘上的大小
+}
+
+// isBackupFile 判断文件名是否为备份文件（不含元数据和临时文件）
+func isBackupFile(name string) bool {
+	return strings.HasPrefix(name, "hosts_") &&
+		!strings.HasSuffix(name, ".json") &&
+		!strings.HasSuffix(name, ".tmp")
+}
+
 // listBackups 获取备份文件列表
 func (app *App) listBackups() ([]string, error) {
 	files, err := os.ReadDir(app.backupDir)
@@ -18,16 +61,129 @@ func (app *App) listBackups() ([]string, error) {
 
 	var backups []string
 	for _, file := range files {
-		if !file.IsDir() && strings.HasPrefix(file.Name(), "hosts_") {
+		if !file.IsDir() && isBackupFile(file.Name()) {
 			backups = append(backups, file.Name())
 		}
 	}
 	return backups, nil
 }
 
+// parseBackupTime 从备份文件名中解析备份时间
+func parseBackupTime(name string) (time.Time, bool) {
+	stamp := strings.TrimPrefix(name, "hosts_")
+	if len(stamp) < len(backupTimeLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(backupTimeLayout, stamp[:len(backupTimeLayout)], time.Local)
+	return t, err == nil
+}
+
+// backupInfos 返回所有备份的信息，按时间从新到旧排列
+// 文件名中没有合法时间的备份使用修改时间
+func (app *App) backupInfos() ([]backupInfo, error) {
+	names, err := app.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []backupInfo
+	for _, name := range names {
+		stat, err := os.Stat(filepath.Join(app.backupDir, name))
+		if err != nil {
+			continue
+		}
+		info := backupInfo{name: name, size: stat.Size()}
+		if t, ok := parseBackupTime(name); ok {
+			info.time = t
+		} else {
+			info.time = stat.ModTime()
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].time.Equal(infos[j].time) {
+			return infos[i].name > infos[j].name
+		}
+		return infos[i].time.After(infos[j].time)
+	})
+	return infos, nil
+}
+
+// backupMetaPath 返回备份对应的元数据文件路径
+func (app *App) backupMetaPath(name string) string {
+	return filepath.Join(app.backupDir, strings.TrimSuffix(name, ".gz")+".json")
+}
+
+// readBackup 读取备份内容，压缩的备份会自动解压
+func readBackup(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return data, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解压备份失败: %w", err)
+	}
+	defer zr.Close()
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("解压备份失败: %w", err)
+	}
+	return content, nil
+}
+
+// newBackupMeta 根据 hosts 内容生成元数据
+func newBackupMeta(content []byte, reason, source string) *BackupMeta {
+	sum := sha256.Sum256(content)
+	return &BackupMeta{
+		Hash:        hex.EncodeToString(sum[:]),
+		Reason:      reason,
+		Source:      source,
+		ToolVersion: appVersion,
+		EntryCount:  len(hostsfile.Parse(content).Entries()),
+		Size:        int64(len(content)),
+		Created:     time.Now().UTC(),
+	}
+}
+
+// loadBackupMeta 读取备份的元数据
+// 旧版本的备份没有元数据文件，此时根据备份内容计算，原因和数据源为空
+func (app *App) loadBackupMeta(name string) (*BackupMeta, error) {
+	data, err := os.ReadFile(app.backupMetaPath(name))
+	if err == nil {
+		var meta BackupMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("备份元数据格式无效: %w", err)
+		}
+		// 被截断或手工修改的元数据文件可能缺少哈希
+		if _, err := hex.DecodeString(meta.Hash); err != nil || len(meta.Hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("备份元数据无效: 哈希值格式错误")
+		}
+		return &meta, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	content, err := readBackup(filepath.Join(app.backupDir, name))
+	if err != nil {
+		return nil, err
+	}
+	meta := newBackupMeta(content, "", "")
+	meta.ToolVersion = ""
+	if t, ok := parseBackupTime(name); ok {
+		meta.Created = t.UTC()
+	}
+	return meta, nil
+}
+
 // listBackupsWithDetails 显示备份列表详情
 func (app *App) listBackupsWithDetails() error {
-	backups, err := app.listBackups()
+	backups, err := app.backupInfos()
 	if err != nil {
 		return err
 	}
@@ -38,23 +194,31 @@ func (app *App) listBackupsWithDetails() error {
 	}
 
 	fmt.Println("\n可用的备份文件：")
-	fmt.Println("序号\t备份时间\t\t文件大小")
-	fmt.Println("----------------------------------------")
-
-	// 按时间排序
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i] > backups[j] // 降序排列
-	})
+	fmt.Println("序号\t备份时间\t\t原因\t\t记录数\t大小\t\t哈希\t\t数据源")
+	fmt.Println(strings.Repeat("-", 100))
 
 	for i, backup := range backups {
-		path := filepath.Join(app.backupDir, backup)
-		info, err := os.Stat(path)
+		meta, err := app.loadBackupMeta(backup.name)
 		if err != nil {
+			fmt.Printf("%d\t%s\t元数据不可用: %v\n", i+1, backup.time.Format("2006-01-02 15:04:05"), err)
 			continue
 		}
-		timeStr := strings.TrimPrefix(backup, "hosts_")
-		timeStr = strings.TrimSuffix(timeStr, filepath.Ext(timeStr))
-		fmt.Printf("%d\t%s\t%.2f KB\n", i+1, timeStr, float64(info.Size())/1024)
+		reason := meta.Reason
+		if reason == "" {
+			reason = "-"
+		}
+		source := meta.Source
+		if source == "" {
+			source = "-"
+		}
+		fmt.Printf("%d\t%s\t%-12s\t%d\t%.2f KB\t%s\t%s\n",
+			i+1,
+			meta.Created.Local().Format("2006-01-02 15:04:05"),
+			reason,
+			meta.EntryCount,
+			float64(meta.Size)/1024,
+			meta.Hash[:12],
+			source)
 	}
 	return nil
 }
@@ -62,56 +226,160 @@ func (app *App) listBackupsWithDetails() error {
 // createNewBackup 创建新的备份
 func (app *App) createNewBackup() error {
 	app.logWithLevel(INFO, "创建新的备份...")
-	if err := app.backupHosts(); err != nil {
+	if _, err := app.backupHosts(backupReasonManual, ""); err != nil {
 		return err
 	}
 	app.logWithLevel(SUCCESS, "备份创建成功")
 	return nil
 }
 
-// backupHosts 备份当前 hosts 文件
-func (app *App) backupHosts() error {
-	timestamp := time.Now().Format("20060102_150405")
-	backupPath := filepath.Join(app.backupDir, fmt.Sprintf("hosts_%s", timestamp))
-
+// backupHosts 以 gzip 压缩备份当前 hosts 文件，并写入元数据
+// 内容与最新的备份相同时不再重复备份；返回本次（或与之相同的已有）备份的文件名
+func (app *App) backupHosts(reason, source string) (string, error) {
 	input, err := os.ReadFile(hostsFile)
 	if err != nil {
-		return err
+		return "", err
+	}
+	meta := newBackupMeta(input, reason, source)
+
+	if backups, err := app.backupInfos(); err == nil && len(backups) > 0 {
+		latest, err := app.loadBackupMeta(backups[0].name)
+		if err == nil && latest.Hash == meta.Hash {
+			app.logWithLevel(INFO, "hosts 内容与最新备份 %s 相同，跳过备份", backups[0].name)
+			return backups[0].name, nil
+		}
 	}
 
-	return os.WriteFile(backupPath, input, 0644)
+	name := app.newBackupName(time.Now())
+	if err := app.writeBackup(name, input, meta); err != nil {
+		return "", err
+	}
+
+	// 每次备份后按保留策略清理旧备份，清理失败不影响本次备份
+	removed, err := app.pruneBackups(false)
+	if err != nil {
+		app.logWithLevel(WARNING, "清理旧备份失败: %v", err)
+	} else if len(removed) > 0 {
+		app.logWithLevel(INFO, "已按保留策略清理 %d 个旧备份", len(removed))
+	}
+	return name, nil
 }
 
-// restoreBackupMenu 显示恢复备份菜单
-func (app *App) restoreBackupMenu() error {
-	backups, err := app.listBackups()
+// newBackupName 返回一个未被占用的备份文件名
+func (app *App) newBackupName(now time.Time) string {
+	base := "hosts_" + now.Format(backupTimeLayout)
+	name := base + ".gz"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(app.backupDir, name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s_%d.gz", base, i)
+	}
+}
+
+// writeBackup 写入压缩的备份文件和元数据文件，两者都先写临时文件再重命名
+// 先写备份再写元数据：中途失败时最多留下没有元数据的备份，按旧版本备份处理，
+// 不会出现元数据指向不存在的备份
+func (app *App) writeBackup(name string, content []byte, meta *BackupMeta) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	metaData, err := json.MarshalIndent(meta, "", "    ")
 	if err != nil {
 		return err
 	}
 
+	path := filepath.Join(app.backupDir, name)
+	if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写入备份失败: %w", err)
+	}
+	if err := writeFileAtomic(app.backupMetaPath(name), metaData, 0644); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("写入备份元数据失败: %w", err)
+	}
+	return nil
+}
+
+// deleteBackup 删除备份文件及其元数据
+func (app *App) deleteBackup(name string) error {
+	if err := os.Remove(filepath.Join(app.backupDir, name)); err != nil {
+		return err
+	}
+	if err := os.Remove(app.backupMetaPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// chooseBackup 显示备份列表并让用户选择，返回备份文件名，取消时返回空字符串
+func (app *App) chooseBackup(prompt string) (string, error) {
+	backups, err := app.backupInfos()
+	if err != nil {
+		return "", err
+	}
+
 	if len(backups) == 0 {
 		app.logWithLevel(INFO, "没有可用的备份文件")
-		return nil
+		return "", nil
 	}
 
 	if err := app.listBackupsWithDetails(); err != nil {
-		return err
+		return "", err
 	}
 
-	fmt.Print("\n请选择要恢复的备份序号（0 取消）: ")
+	fmt.Printf("\n%s（0 取消）: ", prompt)
 	var choice int
 	fmt.Scanf("%d", &choice)
 
 	if choice == 0 {
-		return nil
+		return "", nil
 	}
 
 	if choice < 1 || choice > len(backups) {
-		return fmt.Errorf("无效的选择")
+		return "", fmt.Errorf("无效的选择")
+	}
+	return backups[choice-1].name, nil
+}
+
+// restoreBackupMenu 显示恢复备份菜单
+func (app *App) restoreBackupMenu() error {
+	name, err := app.chooseBackup("请选择要恢复的备份序号")
+	if err != nil || name == "" {
+		return err
+	}
+
+	fmt.Println("\n恢复方式：")
+	fmt.Println("1. 只恢复 GitHub Hosts 管理区块，保留当前的其他内容（默认）")
+	fmt.Println("2. 完整恢复，用备份覆盖整个 hosts 文件")
+	fmt.Print("请选择 [1/2]: ")
+	var mode int
+	fmt.Scanf("%d", &mode)
+	full := mode == 2
+
+	// 恢复前显示当前 hosts 与恢复结果的差异
+	backupFile := filepath.Join(app.backupDir, name)
+	current, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return fmt.Errorf("读取 hosts 文件失败: %w", err)
+	}
+	restored, err := restoredContent(current, backupFile, full)
+	if err != nil {
+		return err
 	}
+	showDiff(diffRefCurrent, name, current, restored, full)
 
 	// 确认恢复
-	fmt.Print("确定要恢复这个备份吗？这将覆盖当前的 hosts 文件 [y/N]: ")
+	if full {
+		fmt.Print("确定要恢复这个备份吗？这将覆盖当前的整个 hosts 文件 [y/N]: ")
+	} else {
+		fmt.Print("确定要恢复这个备份中的 GitHub Hosts 记录吗？[y/N]: ")
+	}
 	var confirm string
 	fmt.Scanf("%s", &confirm)
 
@@ -120,38 +388,16 @@ func (app *App) restoreBackupMenu() error {
 		return nil
 	}
 
-	backupFile := filepath.Join(app.backupDir, backups[choice-1])
-	return app.restoreBackup(backupFile)
+	return app.restoreBackup(backupFile, full)
 }
 
 // deleteBackupMenu 显示删除备份菜单
 func (app *App) deleteBackupMenu() error {
-	backups, err := app.listBackups()
-	if err != nil {
-		return err
-	}
-
-	if len(backups) == 0 {
-		app.logWithLevel(INFO, "没有可用的备份文件")
-		return nil
-	}
-
-	if err := app.listBackupsWithDetails(); err != nil {
+	name, err := app.chooseBackup("请选择要删除的备份序号")
+	if err != nil || name == "" {
 		return err
 	}
 
-	fmt.Print("\n请选择要删除的备份序号（0 取消）: ")
-	var choice int
-	fmt.Scanf("%d", &choice)
-
-	if choice == 0 {
-		return nil
-	}
-
-	if choice < 1 || choice > len(backups) {
-		return fmt.Errorf("无效的选择")
-	}
-
 	// 确认删除
 	fmt.Print("确定要删除这个备份吗？此操作不可恢复 [y/N]: ")
 	var confirm string
@@ -162,8 +408,7 @@ func (app *App) deleteBackupMenu() error {
 		return nil
 	}
 
-	backupFile := filepath.Join(app.backupDir, backups[choice-1])
-	if err := os.Remove(backupFile); err != nil {
+	if err := app.deleteBackup(name); err != nil {
 		return fmt.Errorf("删除备份失败: %w", err)
 	}
 
@@ -171,21 +416,108 @@ func (app *App) deleteBackupMenu() error {
 	return nil
 }
 
-// restoreBackup 恢复指定的备份文件
-func (app *App) restoreBackup(backupFile string) error {
-	// 先创建当前 hosts 文件的备份
-	if err := app.backupHosts(); err != nil {
-		return fmt.Errorf("创建当前 hosts 备份失败: %w", err)
+// diffBackupMenu 选择一个备份并与当前 hosts 或另一个备份比较
+func (app *App) diffBackupMenu() error {
+	name, err := app.chooseBackup("请选择要比较的备份序号")
+	if err != nil || name == "" {
+		return err
+	}
+
+	fmt.Print("请输入比较对象（current 当前 hosts、incoming 数据源新数据或备份序号，默认 current）: ")
+	var target string
+	fmt.Scanf("%s", &target)
+	if target == "" {
+		target = diffRefCurrent
+	}
+	return app.diffRefs(name, target, false)
+}
+
+// manageBackupsMenu 备份管理菜单
+func (app *App) manageBackupsMenu() error {
+	for {
+		fmt.Println("\n[备份管理]")
+		fmt.Println("1. 查看备份列表")
+		fmt.Println("2. 创建备份")
+		fmt.Println("3. 恢复备份")
+		fmt.Println("4. 删除备份")
+		fmt.Println("5. 比较差异")
+		fmt.Println("6. 预览清理（按保留策略）")
+		fmt.Println("7. 预览更新变化")
+		fmt.Println("0. 返回")
+		fmt.Print("请输入选项: ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+
+		var err error
+		switch choice {
+		case 1:
+			err = app.listBackupsWithDetails()
+		case 2:
+			err = app.createNewBackup()
+		case 3:
+			err = app.restoreBackupMenu()
+		case 4:
+			err = app.deleteBackupMenu()
+		case 5:
+			err = app.diffBackupMenu()
+		case 6:
+			err = app.runBackupPrune(true)
+		case 7:
+			err = app.diffRefs(diffRefCurrent, diffRefIncoming, false)
+		case 0:
+			return nil
+		default:
+			fmt.Println("无效的选项，请重试")
+		}
+		if err != nil {
+			app.logWithLevel(ERROR, "%v", err)
+		}
 	}
+}
 
-	// 读取备份文件
-	content, err := os.ReadFile(backupFile)
+// restoredContent 返回从备份恢复后的 hosts 内容
+// full 为 true 时直接使用备份的全部内容；否则只取出备份中的管理区块，替换 current 中的管理区块，其他行保持不变
+func restoredContent(current []byte, backupFile string, full bool) ([]byte, error) {
+	backup, err := readBackup(backupFile)
 	if err != nil {
-		return fmt.Errorf("读取备份文件失败: %w", err)
+		return nil, fmt.Errorf("读取备份文件失败: %w", err)
+	}
+	if full {
+		return backup, nil
+	}
+
+	parsed := hostsfile.Parse(backup)
+	if !parsed.HasBlock() {
+		return nil, fmt.Errorf("备份中没有 GitHub Hosts 管理区块，如需恢复整个文件请使用完整恢复")
+	}
+	return hostsfile.Parse(current).ReplaceBlock(parsed.BlockLines()), nil
+}
+
+// restoreBackup 恢复指定的备份文件，full 为 false 时只恢复管理区块
+func (app *App) restoreBackup(backupFile string, full bool) error {
+	unlock, err := app.lockHosts()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return fmt.Errorf("读取 hosts 文件失败: %w", err)
+	}
+	content, err := restoredContent(current, backupFile, full)
+	if err != nil {
+		return err
+	}
+
+	// 先创建当前 hosts 文件的备份
+	if _, err := app.backupHosts(backupReasonPreRestore, ""); err != nil {
+		return fmt.Errorf("创建当前 hosts 备份失败: %w", err)
 	}
 
 	// 写入到 hosts 文件
-	if err := os.WriteFile(hostsFile, content, 0644); err != nil {
+	if err := writeHostsFile(hostsFile, content); err != nil {
 		return fmt.Errorf("恢复 hosts 文件失败: %w", err)
 	}
 
@@ -194,6 +526,10 @@ func (app *App) restoreBackup(backupFile string) error {
 		app.logWithLevel(WARNING, "DNS 缓存刷新失败: %v", err)
 	}
 
-	app.logWithLevel(SUCCESS, "hosts 文件已恢复")
+	if full {
+		app.logWithLevel(SUCCESS, "hosts 文件已恢复")
+	} else {
+		app.logWithLevel(SUCCESS, "GitHub Hosts 记录已恢复，其他内容保持不变")
+	}
 	return nil
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
+)
+
+// newTestBackupApp 创建使用临时目录的 App，并把 hosts 文件指向临时文件
+func newTestBackupApp(t *testing.T, hosts string) *App {
+	t.Helper()
+	app := newAppWithBaseDir(t.TempDir())
+	if err := os.MkdirAll(app.backupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := hostsFile
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	t.Cleanup(func() { hostsFile = previous })
+	writeTestHosts(t, hosts)
+	return app
+}
+
+func writeTestHosts(t *testing.T, content string) {
+	t.Helper()
+	if err := os.WriteFile(hostsFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const testHosts = "127.0.0.1 localhost\n" +
+	hostsfile.StartMarker + "\n140.82.112.3 github.com\n140.82.112.5 api.github.com\n" + hostsfile.EndMarker + "\n"
+
+func TestWriteBackupRoundTrip(t *testing.T) {
+	app := newTestBackupApp(t, testHosts)
+	content := []byte(testHosts)
+	meta := newBackupMeta(content, backupReasonManual, "test")
+
+	name := app.newBackupName(time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local))
+	if name != "hosts_20240102_030405.gz" {
+		t.Fatalf("newBackupName() = %s", name)
+	}
+	if err := app.writeBackup(name, content, meta); err != nil {
+		t.Fatalf("writeBackup() error = %v", err)
+	}
+
+	// 备份以 gzip 格式保存
+	raw, err := os.ReadFile(filepath.Join(app.backupDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gzip.NewReader(bytes.NewReader(raw)); err != nil {
+		t.Errorf("backup is not gzip: %v", err)
+	}
+	got, err := readBackup(filepath.Join(app.backupDir, name))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("readBackup() = %q, %v", got, err)
+	}
+
+	loaded, err := app.loadBackupMeta(name)
+	if err != nil {
+		t.Fatalf("loadBackupMeta() error = %v", err)
+	}
+	if loaded.Hash != meta.Hash || loaded.Reason != backupReasonManual || loaded.Source != "test" ||
+		loaded.EntryCount != 2 || loaded.Size != int64(len(content)) || !loaded.Created.Equal(meta.Created) {
+		t.Errorf("loadBackupMeta() = %+v, want %+v", loaded, meta)
+	}
+
+	// 不应留下临时文件
+	files, _ := os.ReadDir(app.backupDir)
+	if len(files) != 2 {
+		var names []string
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("backup dir contains %v, want backup and metadata only", names)
+	}
+
+	// 同一秒内的第二个备份使用不同的文件名
+	if next := app.newBackupName(time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)); next != "hosts_20240102_030405_2.gz" {
+		t.Errorf("newBackupName() after existing backup = %s", next)
+	}
+}
+
+func TestWriteBackupMetaFailure(t *testing.T) {
+	app := newTestBackupApp(t, testHosts)
+	name := "hosts_20240102_030405.gz"
+	// 元数据路径被目录占用，写入元数据失败
+	if err := os.Mkdir(app.backupMetaPath(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte(testHosts)
+	if err := app.writeBackup(name, content, newBackupMeta(content, backupReasonManual, "")); err == nil {
+		t.Fatal("writeBackup() error = nil")
+	}
+	if _, err := os.Stat(filepath.Join(app.backupDir, name)); !os.IsNotExist(err) {
+		t.Errorf("backup file left behind after metadata failure: %v", err)
+	}
+}
+
+func TestBackupHostsDedup(t *testing.T) {
+	app := newTestBackupApp(t, testHosts)
+
+	first, err := app.backupHosts(backupReasonPreUpdate, "a")
+	if err != nil {
+		t.Fatalf("backupHosts() error = %v", err)
+	}
+	// 内容未变化时返回已有的备份
+	again, err := app.backupHosts(backupReasonPreUpdate, "b")
+	if err != nil || again != first {
+		t.Fatalf("backupHosts() with same content = %s, %v, want %s", again, err, first)
+	}
+
+	writeTestHosts(t, strings.Replace(testHosts, "140.82.112.3", "140.82.112.4", 1))
+	second, err := app.backupHosts(backupReasonPreUpdate, "c")
+	if err != nil || second == first {
+		t.Fatalf("backupHosts() with new content = %s, %v", second, err)
+	}
+
+	// 只与最新的备份比较：恢复为第一次的内容时仍会备份
+	writeTestHosts(t, testHosts)
+	third, err := app.backupHosts(backupReasonPreUpdate, "d")
+	if err != nil || third == second || third == first {
+		t.Fatalf("backupHosts() with original content = %s, %v", third, err)
+	}
+
+	backups, err := app.backupInfos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 || backups[0].name != third {
+		t.Errorf("backupInfos() = %v, want 3 backups with %s first", backups, third)
+	}
+}
+
+func TestLegacyBackupListing(t *testing.T) {
+	app := newTestBackupApp(t, testHosts)
+
+	// 旧版本的备份：未压缩、没有元数据文件
+	plain := "hosts_20200101_120000"
+	if err := os.WriteFile(filepath.Join(app.backupDir, plain), []byte(testHosts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 没有元数据的压缩备份，例如写入元数据前中断
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("127.0.0.1 localhost\n"))
+	zw.Close()
+	compressed := "hosts_20210101_120000.gz"
+	if err := os.WriteFile(filepath.Join(app.backupDir, compressed), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 临时文件和元数据不应出现在列表中
+	os.WriteFile(filepath.Join(app.backupDir, "hosts_20220101_120000.gz.tmp"), nil, 0644)
+	os.WriteFile(filepath.Join(app.backupDir, ".hosts_20220101_120000.gz.tmp-1"), nil, 0644)
+
+	backups, err := app.backupInfos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].name != compressed || backups[1].name != plain {
+		t.Fatalf("backupInfos() = %v", backups)
+	}
+
+	meta, err := app.loadBackupMeta(plain)
+	if err != nil {
+		t.Fatalf("loadBackupMeta(%s) error = %v", plain, err)
+	}
+	want := newBackupMeta([]byte(testHosts), "", "")
+	if meta.Hash != want.Hash || meta.EntryCount != 2 || meta.Reason != "" || meta.ToolVersion != "" ||
+		!meta.Created.Equal(time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)) {
+		t.Errorf("loadBackupMeta(%s) = %+v", plain, meta)
+	}
+
+	meta, err = app.loadBackupMeta(compressed)
+	if err != nil || meta.EntryCount != 0 || meta.Size != int64(len("127.0.0.1 localhost\n")) {
+		t.Errorf("loadBackupMeta(%s) = %+v, %v", compressed, meta, err)
+	}
+}
+
+func TestLoadBackupMetaInvalidHash(t *testing.T) {
+	app := newTestBackupApp(t, testHosts)
+	name := "hosts_20240102_030405.gz"
+	for _, data := range []string{`{"hash": ""}`, `{"hash": "abc"}`, `{"hash": "` + strings.Repeat("zz", 32) + `"}`} {
+		if err := os.WriteFile(app.backupMetaPath(name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.loadBackupMeta(name); err == nil {
+			t.Errorf("loadBackupMeta() with %s error = nil", data)
+		}
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// bundleFormat 配置包的格式标识
+	bundleFormat = "github-hosts-config-bundle"
+	// bundleVersion 当前配置包的结构版本
+	bundleVersion = 1
+)
+
+// bundleMovedKeys 在配置包中单独存放的配置项
+// bundleStateKeys 只属于本机的运行状态，不导出也不导入
+var (
+	bundleMovedKeys = []string{"domains", "sources", "retention"}
+	bundleStateKeys = []string{"lastUpdate", "version", "lastSource", "lastRollback"}
+)
+
+// ConfigBundle 可在不同机器之间迁移的配置包
+type ConfigBundle struct {
+	Format         string           `json:"format"`
+	BundleVersion  int              `json:"bundleVersion"`
+	ExportedAt     time.Time        `json:"exportedAt"`
+	AppVersion     string           `json:"appVersion"`
+	Config         json.RawMessage  `json:"config"`                   // 自动更新、更新计划、解析方式、探测、健康检查和日志设置
+	Domains        []string         `json:"domains,omitempty"`        // 自定义域名列表，未配置时使用默认列表
+	Sources        []Source         `json:"sources,omitempty"`        // 按顺序尝试的数据源
+	Retention      *RetentionConfig `json:"retention,omitempty"`      // 备份保留策略
+	SecretsOmitted bool             `json:"secretsOmitted,omitempty"` // 数据源的认证令牌和请求头未导出
+}
+
+// configChange 一项配置的变化，Old 或 New 为空表示新增或删除
+type configChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// configImport 导入前准备好的配置和预览
+type configImport struct {
+	current *Config // 当前配置
+	merged  *Config // 导入后的配置
+	changes []configChange
+}
+
+// buildBundle 根据当前配置生成配置包
+// includeSecrets 为 false 时不导出数据源的认证令牌和请求头
+func (app *App) buildBundle(includeSecrets bool) (*ConfigBundle, error) {
+	config, err := app.loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("读取配置失败: %w", err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("当前配置无效，无法导出: %w", err)
+	}
+
+	// 更新计划总是显式写出，导入时不依赖对方程序的默认值
+	schedule := config.schedule()
+	config.Schedule = &schedule
+
+	data, err := config.marshal()
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	for _, key := range append(bundleMovedKeys, bundleStateKeys...) {
+		delete(raw, key)
+	}
+	section, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &ConfigBundle{
+		Format:        bundleFormat,
+		BundleVersion: bundleVersion,
+		ExportedAt:    time.Now().UTC(),
+		AppVersion:    appVersion,
+		Config:        section,
+		Domains:       config.Domains,
+		Retention:     config.Retention,
+	}
+	for _, s := range config.Sources {
+		if !includeSecrets && (s.Token != "" || len(s.Headers) > 0) {
+			s.Token = ""
+			s.Headers = nil
+			bundle.SecretsOmitted = true
+		}
+		bundle.Sources = append(bundle.Sources, s)
+	}
+	return bundle, nil
+}
+
+// exportConfig 导出配置包，path 为空时保存到配置目录，为 - 时输出到标准输出
+func (app *App) exportConfig(path string, includeSecrets bool) error {
+	bundle, err := app.buildBundle(includeSecrets)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if path == "-" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if path == "" {
+		path = filepath.Join(app.baseDir, fmt.Sprintf("config_export_%s.json", time.Now().Format("20060102_150405")))
+	}
+
+	// 包含认证令牌时只允许当前用户读取
+	perm := os.FileMode(0644)
+	if includeSecrets {
+		perm = 0600
+	}
+	if err := os.WriteFile(path, append(data, '\n'), perm); err != nil {
+		return fmt.Errorf("导出配置失败: %w", err)
+	}
+
+	app.logWithLevel(SUCCESS, "配置已导出到: %s", path)
+	if bundle.SecretsOmitted {
+		app.logWithLevel(WARNING, "数据源的认证令牌和请求头未导出（使用 --include-secrets 导出），导入时会保留目标机器上相同数据源的设置")
+	}
+	return nil
+}
+
+// readBundle 读取配置包中的设置
+// 也接受旧版本直接复制的 config.json；返回的配置只有可迁移的设置有意义
+func readBundle(path string) (*Config, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("读取配置包失败: %w", err)
+	}
+
+	var header struct {
+		Format        string `json:"format"`
+		BundleVersion int    `json:"bundleVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, false, describeJSONError(data, err)
+	}
+	if header.Format == "" {
+		config, err := parseConfig(data)
+		return config, false, err
+	}
+	if header.Format != bundleFormat {
+		return nil, false, fmt.Errorf("不是 github-hosts 配置包: format 为 %q", header.Format)
+	}
+	if header.BundleVersion > bundleVersion {
+		return nil, false, fmt.Errorf("配置包由更新版本的程序导出（bundleVersion %d，当前程序支持 %d），请升级程序后再导入",
+			header.BundleVersion, bundleVersion)
+	}
+
+	var bundle ConfigBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, false, describeJSONError(data, err)
+	}
+	if len(bundle.Config) == 0 {
+		return nil, false, fmt.Errorf("配置包缺少 config")
+	}
+	config, err := parseConfig(bundle.Config)
+	if err != nil {
+		return nil, false, fmt.Errorf("配置包中的 config 无效: %w", err)
+	}
+	config.Domains = bundle.Domains
+	config.Sources = bundle.Sources
+	config.Retention = bundle.Retention
+	return config, bundle.SecretsOmitted, nil
+}
+
+// applyPortableSettings 用 src 中可迁移的设置替换 dst 中的对应设置
+// 本机状态（上次更新时间、最近成功的数据源、回滚记录）保持不变；Config 增加可迁移的字段时需要同步这里
+func applyPortableSettings(dst, src *Config, keepSecrets bool) {
+	dst.UpdateInterval = src.UpdateInterval
+	dst.Schedule = src.Schedule
+	dst.Scheduler = src.Scheduler
+	dst.AutoUpdate = src.AutoUpdate
+	dst.Resolver = src.Resolver
+	dst.Domains = src.Domains
+	dst.Probe = src.Probe
+	dst.Retention = src.Retention
+	dst.Guard = src.Guard
+	dst.Log = src.Log
+
+	sources := append([]Source{}, src.Sources...)
+	if keepSecrets {
+		// 配置包不含认证信息时，沿用本机相同数据源的令牌和请求头
+		for i, s := range sources {
+			for _, existing := range dst.Sources {
+				if existing.URL == s.URL {
+					sources[i].Token = existing.Token
+					sources[i].Headers = existing.Headers
+				}
+			}
+		}
+	}
+	dst.Sources = sources
+
+	// 复制后再合并，不修改 dst 原来的 extra（可能与其他 Config 共用）
+	if len(src.extra) > 0 {
+		extra := make(map[string]json.RawMessage)
+		for key, value := range dst.extra {
+			extra[key] = value
+		}
+		for key, value := range src.extra {
+			extra[key] = value
+		}
+		dst.extra = extra
+	}
+}
+
+// prepareImport 读取并校验配置包，生成导入后的配置和与当前配置的差异，不修改任何文件
+func (app *App) prepareImport(path string) (*configImport, error) {
+	incoming, secretsOmitted, err := readBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	if incoming.SchemaVersion > configSchemaVersion {
+		return nil, fmt.Errorf("配置包由更新版本的程序导出（schemaVersion %d，当前程序支持 %d），请升级程序后再导入",
+			incoming.SchemaVersion, configSchemaVersion)
+	}
+
+	// 更新计划是必需的：不能因为缺少或为 0 的 updateInterval 而静默使用默认计划
+	if incoming.Schedule == nil {
+		if incoming.UpdateInterval == 0 {
+			return nil, fmt.Errorf("配置无效: 缺少更新计划（schedule 或 updateInterval）")
+		}
+		schedule := intervalSchedule(incoming.UpdateInterval)
+		incoming.Schedule = &schedule
+	}
+	// 与 updateConfig 一致，updateInterval 供旧版本读取，非固定间隔的计划记为 0
+	incoming.UpdateInterval = 0
+	if incoming.Schedule.Kind == scheduleInterval {
+		incoming.UpdateInterval = incoming.Schedule.Interval
+	}
+	if err := incoming.validate(); err != nil {
+		return nil, err
+	}
+
+	current, err := app.loadConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("读取配置失败: %w", err)
+		}
+		current = &Config{SchemaVersion: configSchemaVersion}
+	}
+	if current.SchemaVersion > configSchemaVersion {
+		return nil, fmt.Errorf("当前配置由更新版本的程序写入（schemaVersion %d），请升级程序后再导入", current.SchemaVersion)
+	}
+
+	merged := *current
+	applyPortableSettings(&merged, incoming, secretsOmitted)
+	if err := merged.validate(); err != nil {
+		return nil, err
+	}
+
+	changes, err := diffConfigs(current, &merged)
+	if err != nil {
+		return nil, err
+	}
+	return &configImport{current: current, merged: &merged, changes: changes}, nil
+}
+
+// printImport 显示导入会带来的配置变化和定时任务变化
+func printImport(imp *configImport) {
+	fmt.Println("\n=== 配置变化（当前 → 导入后）===")
+	printConfigChanges(imp.changes)
+
+	before, after := imp.current.schedule(), imp.merged.schedule()
+	switch {
+	case imp.merged.AutoUpdate && (!imp.current.AutoUpdate || before != after ||
+		imp.current.schedulerName() != imp.merged.schedulerName()):
+		fmt.Printf("\n导入后将按 %s 重新设置定时任务（%s）\n", after, imp.merged.schedulerName())
+	case !imp.merged.AutoUpdate && imp.current.AutoUpdate:
+		fmt.Println("\n导入后将关闭自动更新并移除定时任务")
+	}
+}
+
+// applyImport 保存导入后的配置并同步定时任务，定时任务设置失败时恢复导入前的配置
+func (app *App) applyImport(imp *configImport) error {
+	var previous, saved Config
+	err := app.modifyConfig(func(config *Config) error {
+		previous = *config
+		applyPortableSettings(config, imp.merged, false)
+		saved = *config
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 日志设置可能已改变，下次写入时按新设置重新打开
+	app.closeLog()
+
+	switch {
+	case saved.AutoUpdate:
+		if err := app.setupCron(saved.schedule()); err != nil {
+			app.modifyConfig(func(config *Config) error {
+				applyPortableSettings(config, &previous, false)
+				return nil
+			})
+			return fmt.Errorf("设置定时任务失败，已恢复导入前的配置: %w", err)
+		}
+	case previous.AutoUpdate:
+		app.removeCron()
+	}
+
+	app.logWithLevel(SUCCESS, "配置已导入，共 %d 处变化", len(imp.changes))
+	return nil
+}
+
+// diffConfigs 按配置项比较两份配置，本机状态不参与比较
+func diffConfigs(from, to *Config) ([]configChange, error) {
+	before, err := flattenConfig(from)
+	if err != nil {
+		return nil, err
+	}
+	after, err := flattenConfig(to)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var changes []configChange
+	for key := range keys {
+		if before[key] != after[key] {
+			changes = append(changes, configChange{Key: key, Old: before[key], New: after[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes, nil
+}
+
+// flattenConfig 将配置展开为“配置项路径 → JSON 值”，认证令牌和请求头的值以 *** 代替
+func flattenConfig(c *Config) (map[string]string, error) {
+	data, err := c.marshal()
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	for _, key := range append(bundleStateKeys, "schemaVersion") {
+		delete(root, key)
+	}
+
+	flat := make(map[string]string)
+	var walk func(path string, value interface{})
+	walk = func(path string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				walk(path+"."+key, child)
+			}
+		case []interface{}:
+			for i, child := range v {
+				walk(fmt.Sprintf("%s[%d]", path, i), child)
+			}
+		default:
+			if strings.HasSuffix(path, ".token") || strings.Contains(path, ".headers.") {
+				flat[path] = redactedValue
+				return
+			}
+			encoded, _ := json.Marshal(v)
+			flat[path] = string(encoded)
+		}
+	}
+	for key, value := range root {
+		walk(key, value)
+	}
+	return flat, nil
+}
+
+// printConfigChanges 输出按配置项的变化
+func printConfigChanges(changes []configChange) {
+	if len(changes) == 0 {
+		fmt.Println("配置没有变化")
+		return
+	}
+
+	var added, removed, changed int
+	for _, c := range changes {
+		switch {
+		case c.Old == "":
+			added++
+			fmt.Printf("+ %-35s %s\n", c.Key, c.New)
+		case c.New == "":
+			removed++
+			fmt.Printf("- %-35s %s\n", c.Key, c.Old)
+		default:
+			changed++
+			fmt.Printf("~ %-35s %s → %s\n", c.Key, c.Old, c.New)
+		}
+	}
+	fmt.Printf("\n共 %d 处变化：新增 %d，删除 %d，修改 %d\n", len(changes), added, removed, changed)
+}
+
+// importConfig 从配置包导入设置，dryRun 时只显示差异
+func (app *App) importConfig(path string, dryRun bool) error {
+	imp, err := app.prepareImport(path)
+	if err != nil {
+		return err
+	}
+	printImport(imp)
+	if dryRun {
+		app.logWithLevelOpt(INFO, false, "预览模式，未修改配置")
+		return nil
+	}
+	if len(imp.changes) == 0 {
+		return nil
+	}
+	return app.applyImport(imp)
+}
+
+// configBundleMenu 配置导入导出菜单
+func (app *App) configBundleMenu() error {
+	for {
+		fmt.Println("\n=== 导入/导出配置 ===")
+		fmt.Println("1. 导出配置")
+		fmt.Println("2. 导入配置")
+		fmt.Println("0. 返回")
+		fmt.Print("请输入选项: ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+
+		var err error
+		switch choice {
+		case 1:
+			fmt.Print("请输入导出文件路径（0 保存到配置目录）: ")
+			path := readLine()
+			if path == "0" {
+				path = ""
+			}
+			fmt.Print("是否包含数据源的认证令牌和请求头？[y/N]: ")
+			var response string
+			fmt.Scanf("%s", &response)
+			err = app.exportConfig(path, response == "y" || response == "Y")
+		case 2:
+			fmt.Print("请输入配置包路径: ")
+			path := readLine()
+			var imp *configImport
+			imp, err = app.prepareImport(path)
+			if err != nil {
+				break
+			}
+			printImport(imp)
+			if len(imp.changes) == 0 {
+				break
+			}
+			fmt.Print("\n确定要导入这些设置吗？[y/N]: ")
+			var response string
+			fmt.Scanf("%s", &response)
+			if response != "y" && response != "Y" {
+				app.logWithLevel(INFO, "已取消导入")
+				break
+			}
+			err = app.applyImport(imp)
+		case 0:
+			return nil
+		default:
+			fmt.Println("无效的选项，请重试")
+		}
+		if err != nil {
+			app.logWithLevel(ERROR, "%v", err)
+		}
+	}
+}
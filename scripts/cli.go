@@ -0,0 +1,942 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// 命令行退出码
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// command 定义命令行子命令
+type command struct {
+	name        string
+	usage       string
+	description string
+	needsRoot   bool
+	// rootSubcommands 需要管理员权限的子命令，其他子命令（如只读的 list）普通用户也可运行
+	rootSubcommands []string
+	run             func(app *App, args []string) error
+}
+
+// requiresRoot 判断以 args（不含命令名）运行该命令是否需要管理员权限
+func (c command) requiresRoot(args []string) bool {
+	return c.needsRoot || (len(args) > 0 && containsString(c.rootSubcommands, args[0]))
+}
+
+// usageError 表示命令行参数错误，对应退出码 exitUsage
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// newUsageError 创建参数错误
+func newUsageError(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// commands 返回所有可用的子命令
+func commands() []command {
+	return []command{
+		{
+			name:        "install",
+			usage:       "install [--auto-update=true] [--interval 60 | --at HH:MM | --cron \"分 时 日 月 星期\"] [--jitter 分钟]",
+			description: "安装程序：写入配置、更新 hosts 并设置定时任务",
+			needsRoot:   true,
+			run:         runInstallCommand,
+		},
+		{
+			name:        "update",
+			usage:       "update [--unattended] [--jitter 分钟] [--dry-run]",
+			description: "立即更新 hosts 文件（--unattended 供定时任务调用，--dry-run 只预览变化）",
+			needsRoot:   true,
+			run:         runUpdateCommand,
+		},
+		{
+			name:        "daemon",
+			usage:       "daemon",
+			description: "在前台持续运行，按配置的更新计划定期更新，不使用系统定时任务（SIGHUP 重新读取配置）",
+			needsRoot:   true,
+			run:         runDaemonCommand,
+		},
+		{
+			name:        "uninstall",
+			usage:       "uninstall --yes",
+			description: "卸载程序并清理 hosts 文件",
+			needsRoot:   true,
+			run:         runUninstallCommand,
+		},
+		{
+			name:        "status",
+			usage:       "status [--output text|json]",
+			description: "检查系统状态",
+			run:         runStatusCommand,
+		},
+		{
+			name:        "test",
+			usage:       "test [--probe dns|tcp|tls|http] [--method HEAD|GET] [--expect-status 200-399] [--concurrency 8] [--timeout 10s] [--output text|json]",
+			description: "测试 hosts 中 GitHub 记录的网络连接",
+			run:         runTestCommand,
+		},
+		{
+			name:        "diagnose",
+			usage:       "diagnose [--output text|json]",
+			description: "运行系统诊断",
+			run:         runDiagnoseCommand,
+		},
+		{
+			name:            "backup",
+			usage:           "backup list | create | restore <序号|文件名> --yes [--full] | delete <序号|文件名> --yes | prune [--dry-run]",
+			description:     "管理 hosts 备份（create 和 restore 需要管理员权限）",
+			rootSubcommands: []string{"create", "restore"},
+			run:             runBackupCommand,
+		},
+		{
+			name:        "diff",
+			usage:       "diff [from] [to] [--full]",
+			description: "比较 current（当前 hosts）、incoming（数据源的新数据）或备份（序号|文件名），默认 current incoming，文本差异默认只比较管理区块",
+			run:         runDiffCommand,
+		},
+		{
+			name:        "config",
+			usage:       "config get [key] | set <key> <value> | validate | export [文件|-] [--include-secrets] | import <文件> [--dry-run] [--yes]",
+			description: "查看或修改配置（autoUpdate, updateInterval, schedule, scheduler, resolverMode, probeMode, retention.*, guard.*, log.*），导出或导入配置包",
+			run:         runConfigCommand,
+		},
+		{
+			name:        "source",
+			usage:       "source list | add <url> [--token T] [--header K:V] [--timeout 秒] [--position N] | remove <序号|url> | move <序号|url> <位置>",
+			description: "管理 hosts 数据源（按顺序尝试）",
+			run:         runSourceCommand,
+		},
+		{
+			name:        "history",
+			usage:       "history [--since 7d] [--until 2006-01-02] [--result success|failed|rolled_back] [--trigger manual|scheduled|daemon] [--domain 域名] [-n 20] [--show ID] [--output text|json]",
+			description: "查看更新历史：每次更新的触发方式、结果、耗时、备份和 IP 变化",
+			run:         runHistoryCommand,
+		},
+		{
+			name:        "logs",
+			usage:       "logs [--level warn] [--since 7d] [--until 2006-01-02] [--grep 关键字] [--run ID] [-n 50] [--follow] [--runs] [--output text|json]",
+			description: "查看所有日志文件中的日志，可筛选、实时跟踪或按次显示更新摘要",
+			run:         runLogsCommand,
+		},
+	}
+}
+
+// findCommand 按名称查找子命令
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// printUsage 输出命令行帮助
+func printUsage() {
+	fmt.Println("用法: github-hosts [--base-dir 目录] [命令] [参数]")
+	fmt.Println("不带参数运行时进入交互式菜单。")
+	fmt.Println("\n可用命令:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.usage, cmd.description)
+	}
+	w.Flush()
+}
+
+// runCLI 执行非交互式子命令并返回退出码
+func runCLI(args []string) int {
+	// 解析命令名之前的全局参数
+	global := newFlagSet("github-hosts")
+	baseDir := global.String("base-dir", "", "配置目录，默认为 ~/.github-hosts")
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage()
+			return exitOK
+		}
+		return exitUsage
+	}
+	args = global.Args()
+	if len(args) == 0 {
+		printUsage()
+		return exitUsage
+	}
+
+	name := args[0]
+	switch name {
+	case "help":
+		printUsage()
+		return exitOK
+	}
+
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", name)
+		printUsage()
+		return exitUsage
+	}
+
+	if cmd.requiresRoot(args[1:]) {
+		privileged, err := hasAdminPrivileges()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitFailure
+		}
+		if !privileged {
+			label := name
+			if !cmd.needsRoot {
+				label += " " + args[1]
+			}
+			fmt.Fprintf(os.Stderr, "错误: 命令 %s 需要管理员权限，请使用 sudo 或以管理员身份运行\n", label)
+			return exitFailure
+		}
+	}
+
+	var app *App
+	if *baseDir != "" {
+		app = newAppWithBaseDir(*baseDir)
+	} else {
+		var err error
+		if app, err = NewApp(); err != nil {
+			fmt.Fprintf(os.Stderr, "初始化失败: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	if err := cmd.run(app, args[1:]); err != nil {
+		var uerr *usageError
+		if errors.As(err, &uerr) || errors.Is(err, flag.ErrHelp) {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "参数错误: %v\n", err)
+			}
+			fmt.Fprintf(os.Stderr, "用法: github-hosts %s\n", cmd.usage)
+			return exitUsage
+		}
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// parseArgs 解析参数，允许标志出现在位置参数之后
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, newUsageError("%v", err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// newFlagSet 创建子命令的参数解析器
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func runInstallCommand(app *App, args []string) error {
+	fs := newFlagSet("install")
+	autoUpdate := fs.Bool("auto-update", true, "是否开启自动更新")
+	interval := fs.String("interval", "60", "更新间隔，如 45、90m、2h")
+	at := fs.String("at", "", "每天的更新时间 HH:MM")
+	cronExpr := fs.String("cron", "", "cron 表达式（分 时 日 月 星期）")
+	jitter := fs.Int("jitter", 0, "每次更新前的最大随机延迟（分钟）")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+
+	spec := *interval
+	switch {
+	case *at != "" && *cronExpr != "":
+		return newUsageError("--at 和 --cron 不能同时使用")
+	case *at != "":
+		spec = *at
+	case *cronExpr != "":
+		spec = "cron:" + *cronExpr
+	}
+	schedule, err := parseSchedule(spec)
+	if err != nil {
+		return newUsageError("%v", err)
+	}
+	schedule.Jitter = *jitter
+	if err := schedule.validate(); err != nil {
+		return newUsageError("%v", err)
+	}
+
+	return app.install(installOptions{
+		autoUpdate: *autoUpdate,
+		schedule:   schedule,
+	})
+}
+
+func runUpdateCommand(app *App, args []string) error {
+	fs := newFlagSet("update")
+	unattended := fs.Bool("unattended", false, "无人值守模式，供定时任务调用")
+	dryRun := fs.Bool("dry-run", false, "只显示更新将带来的变化，不修改 hosts 文件")
+	jitter := fs.Int("jitter", 0, "更新前随机等待 0 到 N 分钟")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	if *dryRun {
+		return app.diffRefs(diffRefCurrent, diffRefIncoming, false)
+	}
+
+	app.unattended = *unattended
+	if app.unattended {
+		app.logWithLevel(INFO, "定时任务触发更新")
+	}
+	app.sleepJitter(*jitter)
+
+	if err := app.setupDirectories(); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	if err := app.updateHosts(); err != nil {
+		app.logWithLevel(ERROR, "更新 hosts 失败: %v", err)
+		return err
+	}
+	app.logWithLevel(SUCCESS, "hosts 文件更新完成")
+	return nil
+}
+
+func runUninstallCommand(app *App, args []string) error {
+	fs := newFlagSet("uninstall")
+	yes := fs.Bool("yes", false, "跳过确认直接卸载")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	if !*yes {
+		return newUsageError("卸载将删除所有配置、备份和日志，请添加 --yes 确认")
+	}
+
+	if installed, _ := app.checkInstallStatus(); !installed {
+		return fmt.Errorf("程序未安装")
+	}
+	return app.removeInstallation()
+}
+
+// parseOutputArgs 解析只接受 --output 参数的子命令
+func parseOutputArgs(name string, args []string) (string, error) {
+	fs := newFlagSet(name)
+	output := fs.String("output", outputText, "输出格式: text 或 json")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) > 0 {
+		return "", newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	if err := validateOutputFormat(*output); err != nil {
+		return "", newUsageError("%v", err)
+	}
+	return *output, nil
+}
+
+func runStatusCommand(app *App, args []string) error {
+	output, err := parseOutputArgs("status", args)
+	if err != nil {
+		return err
+	}
+	if output == outputJSON {
+		return writeJSON(app.statusReport())
+	}
+	return app.checkStatus()
+}
+
+func runTestCommand(app *App, args []string) error {
+	opts := defaultConnTestOptions()
+	fs := newFlagSet("test")
+	fs.StringVar(&opts.probe, "probe", opts.probe, "探测方式: dns, tcp, tls 或 http")
+	fs.StringVar(&opts.method, "method", opts.method, "HTTP 探测使用的方法: HEAD 或 GET")
+	expect := fs.String("expect-status", "", "HTTP 探测接受的状态码，如 200,301-399，默认接受所有非 5xx 状态")
+	fs.IntVar(&opts.concurrency, "concurrency", opts.concurrency, "同时测试的域名数量")
+	fs.DurationVar(&opts.timeout, "timeout", opts.timeout, "单个域名的测试时限")
+	output := fs.String("output", outputText, "输出格式: text 或 json")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	if err := validateOutputFormat(*output); err != nil {
+		return newUsageError("%v", err)
+	}
+	opts.method = strings.ToUpper(opts.method)
+	if opts.expectStatus, err = parseStatusRanges(*expect); err != nil {
+		return newUsageError("%v", err)
+	}
+	if err := opts.validate(); err != nil {
+		return newUsageError("%v", err)
+	}
+
+	if *output == outputJSON {
+		report := app.connectionReport(opts)
+		if err := writeJSON(report); err != nil {
+			return err
+		}
+		return report.err()
+	}
+	return app.testConnectionWith(opts)
+}
+
+func runDiagnoseCommand(app *App, args []string) error {
+	output, err := parseOutputArgs("diagnose", args)
+	if err != nil {
+		return err
+	}
+	if output == outputJSON {
+		return writeJSON(app.diagnosticsReport())
+	}
+	return app.runDiagnostics()
+}
+
+func runDaemonCommand(app *App, args []string) error {
+	fs := newFlagSet("daemon")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	return app.runDaemon()
+}
+
+func runDiffCommand(app *App, args []string) error {
+	fs := newFlagSet("diff")
+	full := fs.Bool("full", false, "文本差异比较整个 hosts 文件，而不只是 GitHub Hosts 管理区块")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	from, to := diffRefCurrent, diffRefIncoming
+	switch len(rest) {
+	case 0:
+	case 1:
+		from = rest[0]
+	case 2:
+		from, to = rest[0], rest[1]
+	default:
+		return newUsageError("最多比较两份内容")
+	}
+	return app.diffRefs(from, to, *full)
+}
+
+func runHistoryCommand(app *App, args []string) error {
+	fs := newFlagSet("history")
+	since := fs.String("since", "", "起始时间，如 2006-01-02、24h、7d")
+	until := fs.String("until", "", "结束时间，只有日期时包含当天")
+	result := fs.String("result", "", "只显示指定结果: success, failed 或 rolled_back")
+	trigger := fs.String("trigger", "", "只显示指定触发方式: manual, scheduled 或 daemon")
+	domain := fs.String("domain", "", "只显示该域名 IP 有变化的更新")
+	tail := fs.Int("n", defaultHistoryTail, "只显示最后 N 条，0 表示全部")
+	show := fs.String("show", "", "显示指定 ID 的更新详情")
+	output := fs.String("output", outputText, "输出格式: text 或 json")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	if err := validateOutputFormat(*output); err != nil {
+		return newUsageError("%v", err)
+	}
+
+	if *show != "" {
+		if *output == outputJSON {
+			record, err := app.findHistoryRecord(*show)
+			if err != nil {
+				return err
+			}
+			return writeJSON(record)
+		}
+		return app.showHistoryRecord(*show)
+	}
+
+	q := historyQuery{result: *result, trigger: *trigger, domain: *domain, tail: *tail}
+	if err := validateHistoryResult(q.result); err != nil {
+		return newUsageError("%v", err)
+	}
+	if err := validateHistoryTrigger(q.trigger); err != nil {
+		return newUsageError("%v", err)
+	}
+	if q.since, err = parseLogTime(*since, false); err != nil {
+		return newUsageError("%v", err)
+	}
+	if q.until, err = parseLogTime(*until, true); err != nil {
+		return newUsageError("%v", err)
+	}
+
+	if *output == outputJSON {
+		records, err := app.queryHistory(q)
+		if err != nil {
+			return err
+		}
+		if records == nil {
+			records = []HistoryRecord{}
+		}
+		return writeJSON(HistoryReport{reportHeader: newReportHeader(), Records: records})
+	}
+	return app.showHistory(q)
+}
+
+func runLogsCommand(app *App, args []string) error {
+	fs := newFlagSet("logs")
+	level := fs.String("level", "debug", "显示的最低级别: debug, info, success, warn, error")
+	since := fs.String("since", "", "起始时间，如 2006-01-02、\"2006-01-02 15:04\"、24h、7d")
+	until := fs.String("until", "", "结束时间，只有日期时包含当天")
+	grep := fs.String("grep", "", "只显示包含关键字的日志（不区分大小写）")
+	run := fs.String("run", "", "只显示指定运行 ID 的日志")
+	tail := fs.Int("n", -1, "只显示最后 N 条，0 表示全部")
+	follow := fs.Bool("follow", false, "显示后持续输出新写入的日志")
+	runs := fs.Bool("runs", false, "显示每次更新的摘要")
+	output := fs.String("output", outputText, "输出格式: text 或 json")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+	}
+	if err := validateOutputFormat(*output); err != nil {
+		return newUsageError("%v", err)
+	}
+	if *runs && *follow {
+		return newUsageError("--runs 不能与 --follow 同时使用")
+	}
+
+	q := newLogQuery()
+	if q.minLevel, err = parseLogLevel(*level); err != nil {
+		return newUsageError("%v", err)
+	}
+	if q.since, err = parseLogTime(*since, false); err != nil {
+		return newUsageError("%v", err)
+	}
+	if q.until, err = parseLogTime(*until, true); err != nil {
+		return newUsageError("%v", err)
+	}
+	q.keyword, q.run = *grep, *run
+	switch {
+	case *tail >= 0:
+		q.tail = *tail
+	case *runs:
+		q.tail = defaultRunsTail
+	case *run != "" || *since != "" || *until != "":
+		// 指定了运行或时间范围时默认显示全部
+		q.tail = 0
+	}
+
+	if *runs {
+		if *output == outputJSON {
+			list, err := app.queryRuns(q)
+			if err != nil {
+				return err
+			}
+			return writeJSON(RunsReport{reportHeader: newReportHeader(), Runs: list})
+		}
+		return app.showRuns(q)
+	}
+
+	if *output == outputJSON && !*follow {
+		entries, err := app.queryLogs(q)
+		if err != nil {
+			return err
+		}
+		return writeJSON(LogsReport{reportHeader: newReportHeader(), Entries: entries})
+	}
+	if *output == outputJSON {
+		// 跟踪时每条日志输出一行 JSON
+		entries, err := app.queryLogs(q)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			printLogEntryJSON(e)
+		}
+	} else if err := app.showLogs(q); err != nil {
+		return err
+	}
+	if *follow {
+		return app.followLogs(q, *output)
+	}
+	return nil
+}
+
+func runBackupCommand(app *App, args []string) error {
+	if len(args) == 0 {
+		return newUsageError("缺少子命令")
+	}
+
+	fs := newFlagSet("backup " + args[0])
+	yes := fs.Bool("yes", false, "跳过确认")
+	dryRun := fs.Bool("dry-run", false, "只列出将被删除的备份")
+	full := fs.Bool("full", false, "恢复整个 hosts 文件，而不只是 GitHub Hosts 管理区块")
+	rest, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		return app.listBackupsWithDetails()
+	case "create":
+		if err := app.setupDirectories(); err != nil {
+			return fmt.Errorf("创建目录失败: %w", err)
+		}
+		return app.createNewBackup()
+	case "prune":
+		if len(rest) > 0 {
+			return newUsageError("多余的参数: %s", strings.Join(rest, " "))
+		}
+		return app.runBackupPrune(*dryRun)
+	case "restore", "delete":
+		if len(rest) != 1 {
+			return newUsageError("需要指定一个备份序号或文件名")
+		}
+		if !*yes {
+			return newUsageError("该操作会修改文件且不可撤销，请添加 --yes 确认")
+		}
+		backupFile, err := app.resolveBackup(rest[0])
+		if err != nil {
+			return err
+		}
+		if args[0] == "restore" {
+			return app.restoreBackup(backupFile, *full)
+		}
+		if err := app.deleteBackup(filepath.Base(backupFile)); err != nil {
+			return fmt.Errorf("删除备份失败: %w", err)
+		}
+		app.logWithLevel(SUCCESS, "备份已删除: %s", filepath.Base(backupFile))
+		return nil
+	default:
+		return newUsageError("未知的子命令: %s", args[0])
+	}
+}
+
+// resolveBackup 根据序号（与 backup list 顺序一致）或文件名定位备份文件
+func (app *App) resolveBackup(ref string) (string, error) {
+	backups, err := app.backupInfos()
+	if err != nil {
+		return "", fmt.Errorf("读取备份列表失败: %w", err)
+	}
+
+	if index, err := strconv.Atoi(ref); err == nil {
+		if index < 1 || index > len(backups) {
+			return "", fmt.Errorf("无效的备份序号: %d", index)
+		}
+		return filepath.Join(app.backupDir, backups[index-1].name), nil
+	}
+
+	for _, backup := range backups {
+		if backup.name == filepath.Base(ref) {
+			return filepath.Join(app.backupDir, backup.name), nil
+		}
+	}
+	return "", fmt.Errorf("备份不存在: %s", ref)
+}
+
+func runConfigCommand(app *App, args []string) error {
+	if len(args) == 0 {
+		return newUsageError("缺少子命令")
+	}
+
+	switch args[0] {
+	case "get":
+		config, err := app.loadConfig()
+		if err != nil {
+			return fmt.Errorf("读取配置失败: %w", err)
+		}
+		if len(args) == 1 {
+			data, err := config.redacted().marshal()
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+		if len(args) > 2 {
+			return newUsageError("多余的参数: %s", strings.Join(args[2:], " "))
+		}
+		switch args[1] {
+		case "schemaVersion":
+			fmt.Println(config.SchemaVersion)
+		case "autoUpdate":
+			fmt.Println(config.AutoUpdate)
+		case "updateInterval":
+			fmt.Println(config.UpdateInterval)
+		case "schedule":
+			fmt.Println(config.schedule())
+		case "schedule.jitter":
+			fmt.Println(config.schedule().Jitter)
+		case "scheduler":
+			fmt.Println(config.schedulerName())
+		case "lastUpdate":
+			fmt.Println(config.LastUpdate.Local().Format("2006-01-02 15:04:05"))
+		case "version":
+			fmt.Println(config.Version)
+		case "resolverMode":
+			fmt.Println(config.resolverMode())
+		case "probeMode":
+			fmt.Println(config.probeMode())
+		case "retention.keepLast", "retention.keepDailyDays", "retention.keepWeeklyDays", "retention.maxTotalMB":
+			policy := config.retention()
+			fmt.Println(*policy.field(strings.TrimPrefix(args[1], "retention.")))
+		case "guard.enabled":
+			fmt.Println(config.guard().Enabled)
+		case "guard.probe":
+			fmt.Println(config.guard().Probe)
+		case "guard.maxFailurePercent":
+			fmt.Println(config.guard().MaxFailurePercent)
+		case "log.format":
+			fmt.Println(config.logging().Format)
+		case "log.level":
+			fmt.Println(config.logging().Level)
+		case "log.maxSizeMB":
+			fmt.Println(config.logging().MaxSizeMB)
+		case "log.maxAgeDays":
+			fmt.Println(config.logging().MaxAgeDays)
+		case "log.maxBackups":
+			fmt.Println(config.logging().MaxBackups)
+		case "log.compress":
+			fmt.Println(*config.logging().Compress)
+		default:
+			return newUsageError("未知的配置项: %s", args[1])
+		}
+		return nil
+	case "validate":
+		if len(args) > 1 {
+			return newUsageError("多余的参数: %s", strings.Join(args[1:], " "))
+		}
+		config, err := app.loadConfig()
+		if err != nil {
+			return fmt.Errorf("读取配置失败: %w", err)
+		}
+		if err := config.validate(); err != nil {
+			return err
+		}
+		if config.SchemaVersion > configSchemaVersion {
+			app.logWithLevelOpt(WARNING, false, "配置文件由更新版本的程序写入（schemaVersion %d），当前程序只能读取", config.SchemaVersion)
+		}
+		app.logWithLevelOpt(SUCCESS, false, "配置有效（%s，schemaVersion %d）", app.configFile, config.SchemaVersion)
+		return nil
+	case "export":
+		fs := newFlagSet("config export")
+		includeSecrets := fs.Bool("include-secrets", false, "同时导出数据源的认证令牌和请求头")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) > 1 {
+			return newUsageError("多余的参数: %s", strings.Join(rest[1:], " "))
+		}
+		var path string
+		if len(rest) == 1 {
+			path = rest[0]
+		}
+		return app.exportConfig(path, *includeSecrets)
+	case "import":
+		fs := newFlagSet("config import")
+		dryRun := fs.Bool("dry-run", false, "只显示与当前配置的差异，不修改")
+		yes := fs.Bool("yes", false, "跳过确认")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) != 1 {
+			return newUsageError("需要指定一个配置包文件")
+		}
+		if !*dryRun {
+			if !*yes {
+				return newUsageError("导入会覆盖当前配置，可先用 --dry-run 预览差异，确认后添加 --yes")
+			}
+			if privileged, _ := hasAdminPrivileges(); !privileged {
+				return fmt.Errorf("导入配置需要管理员权限（可能需要更新定时任务）")
+			}
+		}
+		return app.importConfig(rest[0], *dryRun)
+	case "set":
+		if len(args) != 3 {
+			return newUsageError("需要配置项和值")
+		}
+		if privileged, _ := hasAdminPrivileges(); !privileged {
+			return fmt.Errorf("修改配置需要管理员权限（可能需要更新定时任务）")
+		}
+		switch args[1] {
+		case "autoUpdate":
+			enabled, err := strconv.ParseBool(args[2])
+			if err != nil {
+				return newUsageError("autoUpdate 必须是 true 或 false")
+			}
+			return app.setAutoUpdate(enabled)
+		case "updateInterval", "schedule":
+			// updateInterval 只接受间隔，schedule 还接受每天的时间和 cron 表达式
+			schedule, err := parseSchedule(args[2])
+			if err == nil && args[1] == "updateInterval" && schedule.Kind != scheduleInterval {
+				err = fmt.Errorf("updateInterval 只能设置间隔（如 45、90m、2h），其他计划请使用 schedule")
+			}
+			if err != nil {
+				return newUsageError("%v", err)
+			}
+			config, err := app.loadConfig()
+			if err != nil {
+				return fmt.Errorf("读取配置失败: %w", err)
+			}
+			schedule.Jitter = config.schedule().Jitter
+			return app.setSchedule(schedule)
+		case "schedule.jitter":
+			jitter, err := strconv.Atoi(args[2])
+			if err != nil {
+				return newUsageError("schedule.jitter 必须是整数")
+			}
+			config, err := app.loadConfig()
+			if err != nil {
+				return fmt.Errorf("读取配置失败: %w", err)
+			}
+			schedule := config.schedule()
+			schedule.Jitter = jitter
+			if err := schedule.validate(); err != nil {
+				return newUsageError("%v", err)
+			}
+			return app.setSchedule(schedule)
+		case "scheduler":
+			if err := validateSchedulerName(args[2]); err != nil {
+				return newUsageError("%v", err)
+			}
+			return app.setScheduler(args[2])
+		case "resolverMode":
+			if err := validateResolverMode(args[2]); err != nil {
+				return newUsageError("%v", err)
+			}
+			return app.setResolverMode(args[2])
+		case "probeMode":
+			if err := validateProbeMode(args[2]); err != nil {
+				return newUsageError("%v", err)
+			}
+			return app.setProbeMode(args[2])
+		case "retention.keepLast", "retention.keepDailyDays", "retention.keepWeeklyDays", "retention.maxTotalMB":
+			value, err := strconv.Atoi(args[2])
+			if err != nil {
+				return newUsageError("%s 必须是整数", args[1])
+			}
+			return app.setRetention(strings.TrimPrefix(args[1], "retention."), value)
+		case "guard.enabled", "guard.probe", "guard.maxFailurePercent":
+			if err := app.setGuard(strings.TrimPrefix(args[1], "guard."), args[2]); err != nil {
+				return newUsageError("%v", err)
+			}
+			return nil
+		case "log.format", "log.level", "log.maxSizeMB", "log.maxAgeDays", "log.maxBackups", "log.compress":
+			if err := app.setLogging(strings.TrimPrefix(args[1], "log."), args[2]); err != nil {
+				return newUsageError("%v", err)
+			}
+			return nil
+		default:
+			return newUsageError("未知或只读的配置项: %s", args[1])
+		}
+	default:
+		return newUsageError("未知的子命令: %s", args[0])
+	}
+}
+
+// headerFlags 收集可重复的 --header 参数
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(map[string]string(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("请求头格式应为 Key:Value")
+	}
+	h[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	return nil
+}
+
+func runSourceCommand(app *App, args []string) error {
+	if len(args) == 0 {
+		return newUsageError("缺少子命令")
+	}
+
+	fs := newFlagSet("source " + args[0])
+	headers := headerFlags{}
+	token := fs.String("token", "", "以 Bearer 方式发送的认证令牌")
+	timeout := fs.Int("timeout", 0, "请求超时（秒）")
+	position := fs.Int("position", 0, "插入位置，默认追加到末尾")
+	fs.Var(headers, "header", "附加请求头 Key:Value，可重复")
+	rest, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		return app.listSources()
+	case "add":
+		if len(rest) != 1 {
+			return newUsageError("需要指定一个数据源地址")
+		}
+		if err := validateSourceURL(rest[0]); err != nil {
+			return newUsageError("%v", err)
+		}
+		source := Source{URL: rest[0], Token: *token, Timeout: *timeout}
+		if len(headers) > 0 {
+			source.Headers = headers
+		}
+		return app.addSource(source, *position)
+	case "remove":
+		if len(rest) != 1 {
+			return newUsageError("需要指定一个数据源序号或地址")
+		}
+		return app.removeSource(rest[0])
+	case "move":
+		if len(rest) != 2 {
+			return newUsageError("需要指定数据源和目标位置")
+		}
+		to, err := strconv.Atoi(rest[1])
+		if err != nil {
+			return newUsageError("目标位置必须是整数")
+		}
+		return app.moveSource(rest[0], to)
+	default:
+		return newUsageError("未知的子命令: %s", args[0])
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestCommandRequiresRoot(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"backup", "list"}, false},
+		{[]string{"backup", "prune", "--dry-run"}, false},
+		{[]string{"backup", "create"}, true},
+		{[]string{"backup", "restore", "1", "--yes"}, true},
+		{[]string{"backup"}, false},
+		{[]string{"update", "--dry-run"}, true},
+		{[]string{"status"}, false},
+		{[]string{"logs", "-n", "5"}, false},
+	}
+	for _, tt := range tests {
+		cmd, ok := findCommand(tt.args[0])
+		if !ok {
+			t.Fatalf("findCommand(%s) not found", tt.args[0])
+		}
+		if got := cmd.requiresRoot(tt.args[1:]); got != tt.want {
+			t.Errorf("requiresRoot(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,6 @@
 package main
 
-import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
-	"time"
-)
+import "fmt"
 
 // toggleAutoUpdate 切换自动更新状态
 func (app *App) toggleAutoUpdate() error {
@@ -30,118 +22,127 @@ func (app *App) toggleAutoUpdate() error {
 		return nil
 	}
 
+	return app.setAutoUpdate(!config.AutoUpdate)
+}
+
+// setAutoUpdate 开启或关闭自动更新，并同步定时任务
+func (app *App) setAutoUpdate(enabled bool) error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("读取配置失败: %w", err)
+	}
+
 	// 更新配置
-	config.AutoUpdate = !config.AutoUpdate
-	if err := app.updateConfig(config.UpdateInterval, config.AutoUpdate); err != nil {
+	schedule := config.schedule()
+	config.AutoUpdate = enabled
+	if err := app.updateConfig(schedule, config.AutoUpdate); err != nil {
 		return fmt.Errorf("更新配置失败: %w", err)
 	}
 
 	if config.AutoUpdate {
 		// 开启自动更新时，设置定时任务
-		if err := app.setupCron(config.UpdateInterval); err != nil {
+		if err := app.setupCron(schedule); err != nil {
 			app.logWithLevel(ERROR, "设置定时任务失败: %v", err)
 			// 回滚配置
 			config.AutoUpdate = false
-			app.updateConfig(config.UpdateInterval, false)
+			app.updateConfig(schedule, false)
 			return fmt.Errorf("设置定时任务失败: %w", err)
 		}
-		app.logWithLevel(SUCCESS, "自动更新已开启，更新间隔为 %d 分钟", config.UpdateInterval)
+		app.logWithLevel(SUCCESS, "自动更新已开启，更新计划: %s", schedule)
 	} else {
 		// 关闭自动更新时，移除定时任务
-		if runtime.GOOS == "darwin" {
-			exec.Command("launchctl", "bootout", "system/com.github.hosts").Run()
-			os.Remove(darwinPlistPath)
-		} else if runtime.GOOS == "windows" {
-			exec.Command("schtasks", "/delete", "/tn", windowsTaskName, "/f").Run()
-		} else {
-			os.Remove(linuxCronPath)
-		}
+		app.removeCron()
 		app.logWithLevel(SUCCESS, "自动更新已关闭")
 	}
 
 	return nil
 }
 
-// changeUpdateInterval 修改更新间隔
+// changeUpdateInterval 修改更新计划
 func (app *App) changeUpdateInterval() error {
 	config, err := app.loadConfig()
 	if err != nil {
 		return fmt.Errorf("读取配置失败: %w", err)
 	}
 
-	fmt.Println("\n请选择新的更新间隔：")
+	current := config.schedule()
+	fmt.Printf("\n当前更新计划: %s\n", current)
+	schedule, err := promptSchedule()
+	if err != nil {
+		return err
+	}
+	schedule.Jitter = promptJitter(current.Jitter)
+
+	return app.setSchedule(schedule)
+}
+
+// promptSchedule 交互式选择更新计划
+func promptSchedule() (ScheduleConfig, error) {
+	fmt.Println("\n请选择更新计划：")
 	fmt.Println("1. 每 30 分钟")
 	fmt.Println("2. 每 60 分钟")
 	fmt.Println("3. 每 120 分钟")
+	fmt.Println("4. 自定义间隔（如 45、90m、3h）")
+	fmt.Println("5. 每天固定时间")
+	fmt.Println("6. cron 表达式")
+	fmt.Print("请输入选项 (1-6): ")
 
 	var choice int
 	fmt.Scanf("%d", &choice)
 
-	var interval int
 	switch choice {
 	case 1:
-		interval = 30
+		return intervalSchedule(30), nil
 	case 2:
-		interval = 60
+		return intervalSchedule(60), nil
 	case 3:
-		interval = 120
+		return intervalSchedule(120), nil
+	case 4:
+		fmt.Print("请输入更新间隔: ")
+		return parseSchedule(readLine())
+	case 5:
+		fmt.Print("请输入每天的更新时间（HH:MM）: ")
+		return parseSchedule(readLine())
+	case 6:
+		fmt.Print("请输入 cron 表达式（分 时 日 月 星期）: ")
+		return parseSchedule("cron:" + readLine())
 	default:
-		return fmt.Errorf("无效的选项")
+		return ScheduleConfig{}, fmt.Errorf("无效的选项")
 	}
-
-	// 更新配置
-	if err := app.updateConfig(interval, config.AutoUpdate); err != nil {
-		return fmt.Errorf("更新配置失败: %w", err)
-	}
-
-	// 如果启用了自动更新，则更新定时任务
-	if config.AutoUpdate {
-		if err := app.setupCron(interval); err != nil {
-			return fmt.Errorf("更新定时任务失败: %w", err)
-		}
-	}
-
-	app.logWithLevel(SUCCESS, "更新间隔已修改为 %d 分钟", interval)
-	return nil
 }
 
-// exportConfigToFile 导出配置到文件
-func (app *App) exportConfigToFile() error {
-	exportPath := filepath.Join(app.baseDir, fmt.Sprintf("config_export_%s.json", time.Now().Format("20060102_150405")))
-
-	data, err := os.ReadFile(app.configFile)
-	if err != nil {
-		return fmt.Errorf("读取配置失败: %w", err)
-	}
-
-	if err := os.WriteFile(exportPath, data, 0644); err != nil {
-		return fmt.Errorf("导出配置失败: %w", err)
+// promptJitter 交互式输入随机延迟，直接回车保持 current
+func promptJitter(current int) int {
+	fmt.Printf("请输入最大随机延迟（分钟，0 表示不延迟，直接回车保持 %d）: ", current)
+	var jitter int
+	if n, _ := fmt.Scanf("%d", &jitter); n == 0 || jitter < 0 || jitter > maxJitter {
+		return current
 	}
-
-	app.logWithLevel(SUCCESS, "配置已导出到: %s", exportPath)
-	return nil
+	return jitter
 }
 
-// importConfigFromFile 从文件导入配置
-func (app *App) importConfigFromFile() error {
-	fmt.Print("请输入配置文件路径: ")
-	var path string
-	fmt.Scanf("%s", &path)
+// setSchedule 修改更新计划，自动更新开启时同步定时任务
+func (app *App) setSchedule(schedule ScheduleConfig) error {
+	if err := schedule.validate(); err != nil {
+		return err
+	}
 
-	data, err := os.ReadFile(path)
+	config, err := app.loadConfig()
 	if err != nil {
-		return fmt.Errorf("读取配置文件失败: %w", err)
+		return fmt.Errorf("读取配置失败: %w", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return fmt.Errorf("配置文件格式无效: %w", err)
+	// 先同步定时任务，当前平台无法表示该计划时不修改配置
+	if config.AutoUpdate {
+		if err := app.setupCron(schedule); err != nil {
+			return fmt.Errorf("更新定时任务失败: %w", err)
+		}
 	}
 
-	if err := os.WriteFile(app.configFile, data, 0644); err != nil {
+	if err := app.updateConfig(schedule, config.AutoUpdate); err != nil {
 		return fmt.Errorf("更新配置失败: %w", err)
 	}
 
-	app.logWithLevel(SUCCESS, "配置导入成功")
+	app.logWithLevel(SUCCESS, "更新计划已修改为: %s", schedule)
 	return nil
 }
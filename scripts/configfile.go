@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configSchemaVersion 当前配置文件的结构版本
+// 修改配置结构时递增，并在 configMigrations 中加入对应的迁移
+const configSchemaVersion = 2
+
+// configLockTimeout 等待其他进程释放配置锁的最长时间
+const configLockTimeout = 10 * time.Second
+
+// configMigrations 按顺序将配置从旧版本迁移到新版本
+// 第 i 个迁移把 schemaVersion i+1 的配置升级为 i+2，直接修改原始 JSON 字段，未知字段保持不变
+var configMigrations = []func(raw map[string]json.RawMessage) error{
+	migrateConfigV1,
+}
+
+// migrateConfigV1 没有 schemaVersion 的旧配置只有 updateInterval，转换为显式的更新计划
+func migrateConfigV1(raw map[string]json.RawMessage) error {
+	if _, ok := raw["schedule"]; ok {
+		return nil
+	}
+	var interval int
+	if value, ok := raw["updateInterval"]; ok {
+		if err := json.Unmarshal(value, &interval); err != nil {
+			return fmt.Errorf("updateInterval 应为整数: %w", err)
+		}
+	}
+	if interval <= 0 {
+		return nil
+	}
+	schedule, err := json.Marshal(intervalSchedule(interval))
+	if err != nil {
+		return err
+	}
+	raw["schedule"] = schedule
+	return nil
+}
+
+// configKnownKeys 返回 Config 中定义的 JSON 字段名
+func configKnownKeys() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// parseConfig 解析配置文件内容，按需迁移到当前结构版本，并保留不认识的字段
+// 由更新版本的程序写入的配置不做迁移，可以读取但不能保存
+func parseConfig(data []byte) (*Config, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, describeJSONError(data, err)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("配置文件内容应为 JSON 对象")
+	}
+
+	version := 1
+	if value, ok := raw["schemaVersion"]; ok {
+		if err := json.Unmarshal(value, &version); err != nil || version < 1 {
+			return nil, fmt.Errorf("schemaVersion 应为正整数")
+		}
+	}
+	for v := version; v < configSchemaVersion; v++ {
+		if err := configMigrations[v-1](raw); err != nil {
+			return nil, fmt.Errorf("迁移配置到 schemaVersion %d 失败: %w", v+1, err)
+		}
+	}
+	if version < configSchemaVersion {
+		raw["schemaVersion"] = json.RawMessage(fmt.Sprint(configSchemaVersion))
+	}
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(migrated, &config); err != nil {
+		return nil, describeJSONError(migrated, err)
+	}
+
+	known := configKnownKeys()
+	for key, value := range raw {
+		if known[key] {
+			continue
+		}
+		if config.extra == nil {
+			config.extra = make(map[string]json.RawMessage)
+		}
+		config.extra[key] = value
+	}
+	return &config, nil
+}
+
+// describeJSONError 将 JSON 解析错误转换为带行列号或字段名的说明
+func describeJSONError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, col := offsetPosition(data, syntaxErr.Offset)
+		return fmt.Errorf("配置文件第 %d 行第 %d 列附近有语法错误: %v", line, col, syntaxErr)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "(根)"
+		}
+		return fmt.Errorf("配置项 %s 的类型错误: 应为 %s，实际为 %s", field, typeErr.Type, typeErr.Value)
+	}
+	return fmt.Errorf("配置文件格式无效: %w", err)
+}
+
+// offsetPosition 将字节偏移转换为从 1 开始的行列号
+func offsetPosition(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// marshal 生成配置文件内容，不认识的字段按名称排序追加在已知字段之后
+func (c *Config) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	if len(c.extra) == 0 {
+		return data, nil
+	}
+
+	keys := make([]string, 0, len(c.extra))
+	for key := range c.extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// 去掉结尾的 }，在最后一个已知字段之后继续写入
+	var b bytes.Buffer
+	b.Write(bytes.TrimRight(bytes.TrimSuffix(bytes.TrimRight(data, "\n"), []byte("}")), "\n"))
+	for _, key := range keys {
+		var value bytes.Buffer
+		if err := json.Indent(&value, c.extra[key], "    ", "    "); err != nil {
+			return nil, fmt.Errorf("配置项 %s 的内容无效: %w", key, err)
+		}
+		name, _ := json.Marshal(key)
+		fmt.Fprintf(&b, ",\n    %s: %s", name, value.Bytes())
+	}
+	b.WriteString("\n}")
+	return b.Bytes(), nil
+}
+
+// validate 检查配置中各项的取值，返回所有问题
+func (c *Config) validate() error {
+	var problems []string
+	check := func(field string, err error) {
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", field, err))
+		}
+	}
+
+	if c.UpdateInterval != 0 {
+		check("updateInterval", validateInterval(c.UpdateInterval))
+	}
+	if c.Schedule != nil {
+		check("schedule", c.Schedule.validate())
+	}
+	if c.Scheduler != "" {
+		check("scheduler", validateSchedulerName(c.Scheduler))
+	}
+	for i, source := range c.Sources {
+		check(fmt.Sprintf("sources[%d].url", i), validateSourceURL(source.URL))
+		if source.Timeout < 0 {
+			check(fmt.Sprintf("sources[%d].timeout", i), fmt.Errorf("不能为负数"))
+		}
+	}
+	if c.Resolver != nil {
+		if c.Resolver.Mode != "" {
+			check("resolver.mode", validateResolverMode(c.Resolver.Mode))
+		}
+		for i, p := range c.Resolver.Providers {
+			check(fmt.Sprintf("resolver.providers[%d].url", i), validateSourceURL(p.URL))
+			if p.Format != dohFormatJSON && p.Format != dohFormatWire {
+				check(fmt.Sprintf("resolver.providers[%d].format", i), fmt.Errorf("应为 json 或 wire"))
+			}
+		}
+	}
+	if c.Probe != nil {
+		if c.Probe.Mode != "" {
+			check("probe.mode", validateProbeMode(c.Probe.Mode))
+		}
+		if c.Probe.Attempts < 0 || c.Probe.Timeout < 0 {
+			check("probe", fmt.Errorf("attempts 和 timeout 不能为负数"))
+		}
+	}
+	if c.Retention != nil {
+		check("retention", c.Retention.validate())
+	}
+	if c.Guard != nil {
+		if c.Guard.Probe != "" {
+			check("guard.probe", validateGuardProbe(c.Guard.Probe))
+		}
+		if c.Guard.MaxFailurePercent < 0 || c.Guard.MaxFailurePercent > 100 {
+			check("guard.maxFailurePercent", fmt.Errorf("应在 1 到 100 之间"))
+		}
+	}
+	if c.Log != nil {
+		if c.Log.Format != "" && c.Log.Format != logFormatText && c.Log.Format != logFormatJSON {
+			check("log.format", fmt.Errorf("应为 text 或 json"))
+		}
+		if c.Log.Level != "" {
+			_, err := parseLogLevel(c.Log.Level)
+			check("log.level", err)
+		}
+		if c.Log.MaxSizeMB < 0 || c.Log.MaxAgeDays < 0 || c.Log.MaxBackups < 0 {
+			check("log", fmt.Errorf("maxSizeMB、maxAgeDays 和 maxBackups 不能为负数"))
+		}
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("配置无效: %s", problems[0])
+	default:
+		return fmt.Errorf("配置有 %d 处无效:\n  - %s", len(problems), strings.Join(problems, "\n  - "))
+	}
+}
+
+// loadConfig 读取配置文件
+// 文件不存在时返回的错误满足 os.IsNotExist
+func (app *App) loadConfig() (*Config, error) {
+	data, err := os.ReadFile(app.configFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data)
+}
+
+// loadConfigOrDefault 读取配置文件；文件不存在时返回 nil，各配置项使用默认值
+// 文件无法解析、迁移或校验失败时返回错误，避免配置损坏时静默按默认配置运行
+func (app *App) loadConfigOrDefault() (*Config, error) {
+	config, err := app.loadConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取配置失败: %w", err)
+	}
+	return config, nil
+}
+
+// saveConfig 校验并以原子方式保存配置文件
+func (app *App) saveConfig(config *Config) error {
+	if config.SchemaVersion > configSchemaVersion {
+		return fmt.Errorf("配置文件由更新版本的程序写入（schemaVersion %d，当前程序支持 %d），请升级程序后再修改配置",
+			config.SchemaVersion, configSchemaVersion)
+	}
+	config.SchemaVersion = configSchemaVersion
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	data, err := config.marshal()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(app.configFile), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	// 与 hosts 文件相同，先写临时文件再重命名，避免中断时留下不完整的配置
+	// 数据源带有认证令牌或请求头时只允许当前用户读取
+	perm := os.FileMode(0)
+	if config.hasCredentials() {
+		perm = 0600
+	}
+	return writeFileAtomic(app.configFile, data, perm)
+}
+
+// lockConfig 获取配置文件的独占锁，避免多个进程同时读取、修改、保存配置时相互覆盖
+func (app *App) lockConfig() (func(), error) {
+	if err := os.MkdirAll(app.baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	path := filepath.Join(app.baseDir, "config.lock")
+	deadline := time.Now().Add(configLockTimeout)
+	for {
+		unlock, err := tryLockFile(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("获取配置锁失败: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("另一个进程正在修改配置，等待超时")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// modifyConfig 在配置锁内读取配置、调用 mutate 修改后保存
+// 配置文件不存在时从空配置开始；mutate 返回错误时不保存
+func (app *App) modifyConfig(mutate func(config *Config) error) error {
+	unlock, err := app.lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config, err := app.loadConfig()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("读取配置失败: %w", err)
+		}
+		config = &Config{}
+	}
+	if err := mutate(config); err != nil {
+		return err
+	}
+	if err := app.saveConfig(config); err != nil {
+		return fmt.Errorf("保存配置失败: %w", err)
+	}
+	return nil
+}
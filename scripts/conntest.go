@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
+)
+
+// 连接测试的探测方式
+const (
+	connProbeDNS  = "dns"  // 只检查系统解析结果
+	connProbeTCP  = "tcp"  // 与 hosts 中的 IP 建立 TCP 连接
+	connProbeTLS  = "tls"  // 与 hosts 中的 IP 完成 TLS 握手并校验证书
+	connProbeHTTP = "http" // 经 hosts 中的 IP 发送 HTTP 请求并检查状态码（默认）
+)
+
+const (
+	// defaultConnTestConcurrency 默认同时测试的域名数量
+	defaultConnTestConcurrency = 8
+	// defaultConnTestTimeout 默认的单个域名测试时限
+	defaultConnTestTimeout = 10 * time.Second
+)
+
+// connTestOptions 连接测试参数
+type connTestOptions struct {
+	probe        string
+	method       string        // HTTP 探测使用的方法：HEAD 或 GET
+	expectStatus []statusRange // HTTP 探测接受的状态码，为空时接受所有非 5xx 状态
+	concurrency  int
+	timeout      time.Duration // 单个域名的测试时限
+}
+
+// defaultConnTestOptions 返回默认的测试参数
+func defaultConnTestOptions() connTestOptions {
+	return connTestOptions{
+		probe:       connProbeHTTP,
+		method:      http.MethodHead,
+		concurrency: defaultConnTestConcurrency,
+		timeout:     defaultConnTestTimeout,
+	}
+}
+
+// validate 检查测试参数
+func (o connTestOptions) validate() error {
+	switch o.probe {
+	case connProbeDNS, connProbeTCP, connProbeTLS, connProbeHTTP:
+	default:
+		return fmt.Errorf("无效的探测方式: %s（可选 dns, tcp, tls, http）", o.probe)
+	}
+	if o.method != http.MethodHead && o.method != http.MethodGet {
+		return fmt.Errorf("无效的 HTTP 方法: %s（可选 HEAD, GET）", o.method)
+	}
+	if o.concurrency < 1 {
+		return fmt.Errorf("并发数必须大于 0")
+	}
+	if o.timeout <= 0 {
+		return fmt.Errorf("超时时间必须大于 0")
+	}
+	return nil
+}
+
+// statusRange 一段可接受的 HTTP 状态码
+type statusRange struct {
+	min, max int
+}
+
+// parseStatusRanges 解析 "200,301-399" 形式的状态码列表
+func parseStatusRanges(s string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		min, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("无效的状态码: %s", part)
+		}
+		max := min
+		if isRange {
+			if max, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("无效的状态码: %s", part)
+			}
+		}
+		if min < 100 || max > 599 || min > max {
+			return nil, fmt.Errorf("无效的状态码范围: %s", part)
+		}
+		ranges = append(ranges, statusRange{min: min, max: max})
+	}
+	return ranges, nil
+}
+
+// statusAccepted 判断 HTTP 状态码是否符合预期
+func (o connTestOptions) statusAccepted(code int) bool {
+	if len(o.expectStatus) == 0 {
+		return code < 500
+	}
+	for _, r := range o.expectStatus {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// connectionReport 并发测试 hosts 管理区块中每条记录的连接情况
+func (app *App) connectionReport(opts connTestOptions) *ConnectionReport {
+	report := &ConnectionReport{reportHeader: newReportHeader(), Probe: opts.probe, Results: []DomainTestResult{}}
+
+	// 读取 hosts 文件
+	content, err := os.ReadFile(hostsFile)
+	if err != nil {
+		report.Error = fmt.Sprintf("无法读取 hosts 文件: %v", err)
+		return report
+	}
+
+	// 解析 hosts 文件管理区块中的记录
+	tests := hostsfile.Parse(content).Entries()
+	if len(tests) == 0 {
+		report.Error = "在 hosts 文件中未找到 GitHub 相关记录"
+		return report
+	}
+
+	tester := newConnTester(opts)
+	results := make([]DomainTestResult, len(tests))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.concurrency && w < len(tests); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = tester.test(tests[i])
+			}
+		}()
+	}
+	for i := range tests {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var latencies []float64
+	for _, result := range results {
+		if result.OK {
+			report.Passed++
+			latencies = append(latencies, result.LatencyMs)
+		} else {
+			report.Failed++
+		}
+	}
+	report.Results = results
+	report.Total = len(results)
+	report.P50Ms = percentile(latencies, 50)
+	report.P95Ms = percentile(latencies, 95)
+	return report
+}
+
+// percentile 返回 values 的第 p 百分位数（最近秩法），values 为空时返回 0
+func percentile(values []float64, p int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// connTester 执行单个域名的连接测试
+type connTester struct {
+	opts connTestOptions
+}
+
+// newConnTester 创建连接测试器
+func newConnTester(opts connTestOptions) *connTester {
+	return &connTester{opts: opts}
+}
+
+// test 在时限内测试单个域名
+// 连接直接发往 hosts 记录中的 IP，SNI 和 Host 仍使用域名，因此结果不受系统解析缓存影响；
+// 分别报告 hosts 记录是否可达、系统解析是否指向该记录、证书是否对域名有效
+func (t *connTester) test(entry HostEntry) DomainTestResult {
+	return t.testContext(context.Background(), entry)
+}
+
+// testContext 与 test 相同，parent 取消或到期时测试随之结束
+func (t *connTester) testContext(parent context.Context, entry HostEntry) DomainTestResult {
+	result := DomainTestResult{Domain: entry.Domain, ExpectedIP: entry.IP}
+	ctx, cancel := context.WithTimeout(parent, t.opts.timeout)
+	defer cancel()
+
+	start := time.Now()
+	if t.opts.probe != connProbeDNS {
+		t.checkEntry(ctx, entry, &result)
+	}
+
+	// 检查系统解析结果是否包含 hosts 中的 IP
+	addrs, err := net.DefaultResolver.LookupHost(ctx, entry.Domain)
+	if t.opts.probe == connProbeDNS {
+		result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}
+	if err != nil {
+		result.ResolveError = err.Error()
+	} else if len(addrs) > 0 {
+		result.ResolvedIP = addrs[0]
+		if containsString(addrs, entry.IP) {
+			result.ResolvedIP = entry.IP
+			result.SystemResolves = true
+		}
+	}
+
+	// 按连接、证书、HTTP 状态、系统解析的顺序确定状态
+	switch {
+	case result.Status != "":
+	case result.CertValid != nil && !*result.CertValid:
+		result.Status = "cert_invalid"
+	case result.HTTPStatus != 0 && !t.opts.statusAccepted(result.HTTPStatus):
+		result.Status = "http_status"
+	case result.ResolveError != "":
+		result.Status = "dns_failed"
+		result.Error = result.ResolveError
+	case !result.SystemResolves:
+		result.Status = "ip_mismatch"
+	default:
+		result.Status = "ok"
+		result.OK = true
+	}
+	return result
+}
+
+// checkEntry 按探测方式连接 hosts 记录中的 IP，连接失败时设置 Status
+func (t *connTester) checkEntry(ctx context.Context, entry HostEntry, result *DomainTestResult) {
+	start := time.Now()
+	defer func() {
+		result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	switch t.opts.probe {
+	case connProbeTCP:
+		conn, err := dialEntry(ctx, entry, result)
+		if err == nil {
+			conn.Close()
+		}
+	case connProbeTLS:
+		conn, err := dialEntryTLS(ctx, entry, result)
+		if err == nil {
+			conn.Close()
+		}
+	case connProbeHTTP:
+		// 拨号在 Transport 的 goroutine 中进行，Do 因超时返回时拨号可能仍未结束；
+		// 拨号结果先写入自己的副本，完成后在锁内交出，Do 返回后再合并到 result
+		var mu sync.Mutex
+		var dialed *DomainTestResult
+		client := &http.Client{
+			Transport: &http.Transport{
+				DisableKeepAlives: true,
+				DialTLSContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var r DomainTestResult
+					conn, err := dialEntryTLS(ctx, entry, &r)
+					mu.Lock()
+					dialed = &r
+					mu.Unlock()
+					return conn, err
+				},
+			},
+			// 不跟随重定向，以便检查原始状态码
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+		req, err := http.NewRequestWithContext(ctx, t.opts.method, "https://"+entry.Domain, nil)
+		if err != nil {
+			result.Status = "connect_failed"
+			result.Error = err.Error()
+			return
+		}
+		resp, err := client.Do(req)
+		mu.Lock()
+		if dialed != nil {
+			result.Reachable = dialed.Reachable
+			result.CertValid = dialed.CertValid
+			result.CertError = dialed.CertError
+			result.Status = dialed.Status
+			result.Error = dialed.Error
+		}
+		mu.Unlock()
+		if err != nil {
+			if result.Status == "" {
+				result.Status = "connect_failed"
+				result.Error = err.Error()
+			}
+			return
+		}
+		resp.Body.Close()
+		result.HTTPStatus = resp.StatusCode
+	}
+}
+
+// dialEntry 建立到 hosts 记录中 IP 的 443 端口的 TCP 连接
+func dialEntry(ctx context.Context, entry HostEntry, result *DomainTestResult) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(entry.IP, "443"))
+	reachable := err == nil
+	result.Reachable = &reachable
+	if err != nil {
+		result.Status = "connect_failed"
+		result.Error = err.Error()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dialEntryTLS 连接 hosts 记录中的 IP 并以域名作为 SNI 完成 TLS 握手
+// 握手时不校验证书，握手完成后单独校验证书是否对域名有效，以便区分连接问题和证书问题
+func dialEntryTLS(ctx context.Context, entry HostEntry, result *DomainTestResult) (net.Conn, error) {
+	conn, err := dialEntry(ctx, entry, result)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: entry.Domain, InsecureSkipVerify: true})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		result.Status = "tls_failed"
+		result.Error = err.Error()
+		return nil, err
+	}
+
+	valid := true
+	if err := verifyPeerCertificate(tlsConn.ConnectionState(), entry.Domain); err != nil {
+		valid = false
+		result.CertError = err.Error()
+	}
+	result.CertValid = &valid
+	return tlsConn, nil
+}
+
+// verifyPeerCertificate 使用系统根证书校验服务器证书链及域名
+func verifyPeerCertificate(state tls.ConnectionState, domain string) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("服务器未提供证书")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       domain,
+		Intermediates: intermediates,
+	})
+	return err
+}
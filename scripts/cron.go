@@ -1,135 +1,95 @@
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"text/template"
+	"strings"
 )
 
-// setupCron 根据操作系统设置定时任务
-func (app *App) setupCron(interval int) error {
-	// 创建更新脚本
-	scriptPath := filepath.Join(app.baseDir, "update.sh")
+// binaryPath 返回安装后程序文件的路径
+func (app *App) binaryPath() string {
+	name := "github-hosts"
 	if runtime.GOOS == "windows" {
-		scriptPath = filepath.Join(app.baseDir, "update.bat")
+		name += ".exe"
 	}
+	return filepath.Join(app.baseDir, "bin", name)
+}
+
+// installBinary 将当前程序复制到配置目录，保证定时任务引用的路径稳定
+func (app *App) installBinary() (string, error) {
+	target := app.binaryPath()
 
-	if err := app.createUpdateScript(scriptPath); err != nil {
-		return fmt.Errorf("创建更新脚本失败: %w", err)
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("获取程序路径失败: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	if exe == target {
+		return target, nil
 	}
 
-	// 根据操作系统选择不同的定时任务实现
-	switch runtime.GOOS {
-	case "darwin":
-		return app.setupDarwinCron(interval, scriptPath)
-	case "linux":
-		return app.setupLinuxCron(interval, scriptPath)
-	case "windows":
-		return app.setupWindowsCron(interval, scriptPath)
-	default:
-		return fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
 	}
-}
 
-// createUpdateScript 创建更新脚本
-func (app *App) createUpdateScript(path string) error {
-	var content string
-	if runtime.GOOS == "windows" {
-		// Windows 批处理脚本
-		content = `@echo off
-echo [%date% %time%] 开始更新 hosts 文件... >> "{{.LogDir}}\update.log"
+	src, err := os.Open(exe)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
 
-:: 清理已存在的 GitHub Hosts 内容
-powershell -Command "& {(Get-Content '{{.HostsFile}}') -notmatch '===== GitHub Hosts (Start|End) =====' | Set-Content '{{.HostsFile}}.tmp'}"
-move /Y "{{.HostsFile}}.tmp" "{{.HostsFile}}"
+	// 先写入临时文件再重命名，避免覆盖正在运行的程序
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
 
-:: 获取新的 hosts 内容
-echo # ===== GitHub Hosts Start ===== >> "{{.HostsFile}}"
-powershell -Command "& {(New-Object System.Net.WebClient).DownloadString('{{.HostsAPI}}')}" >> "{{.HostsFile}}"
-echo # ===== GitHub Hosts End ===== >> "{{.HostsFile}}"
+	return target, nil
+}
 
-:: 刷新 DNS 缓存
-ipconfig /flushdns
+// schtasksScheduler Windows 计划任务
+type schtasksScheduler struct{}
 
-echo [%date% %time%] 更新完成 >> "{{.LogDir}}\update.log"
-`
-	} else {
-		// Unix 系统脚本
-		content = `#!/bin/bash
-LOG_FILE="{{.LogDir}}/update_$(date +%Y%m%d).log"
-TIMESTAMP=$(date '+%Y-%m-%d %H:%M:%S')
-
-log() {
-    echo "[$TIMESTAMP] $1" >> "$LOG_FILE"
-}
-
-log "开始更新 hosts 文件..."
-
-# 清理已存在的 GitHub Hosts 内容
-sed -i.bak '/# ===== GitHub Hosts Start =====/,/# ===== GitHub Hosts End =====/d' {{.HostsFile}}
-
-# 获取新的 hosts 内容
-echo "# ===== GitHub Hosts Start =====" >> {{.HostsFile}}
-curl -fsSL {{.HostsAPI}} >> {{.HostsFile}}
-echo "# ===== GitHub Hosts End =====" >> {{.HostsFile}}
-
-# 刷新 DNS 缓存
-if [ "$(uname)" == "Darwin" ]; then
-    killall -HUP mDNSResponder
-    log "已刷新 MacOS DNS 缓存"
-else
-    if systemd-resolve --flush-caches > /dev/null 2>&1; then
-        log "已刷新 Linux DNS 缓存"
-    elif systemctl restart systemd-resolved > /dev/null 2>&1; then
-        log "已重启 systemd-resolved 服务"
-    fi
-fi
-
-log "更新完成"
-`
-	}
-
-	// 准备模板数据
-	data := struct {
-		LogDir    string
-		HostsFile string
-		HostsAPI  string
-	}{
-		LogDir:    app.logDir,
-		HostsFile: hostsFile,
-		HostsAPI:  hostsAPI,
-	}
-
-	// 解析并执行模板
-	tmpl, err := template.New("script").Parse(content)
-	if err != nil {
-		return err
-	}
+func (schtasksScheduler) name() string { return schedulerSchtasks }
+
+func (schtasksScheduler) available() bool { return commandExists("schtasks") }
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+func (schtasksScheduler) install(job scheduledJob) error {
+	trigger, err := job.schedule.schtasksArgs()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	return tmpl.Execute(f, data)
-}
-
-// setupWindowsCron 设置 Windows 计划任务
-func (app *App) setupWindowsCron(interval int, scriptPath string) error {
 	// 删除已存在的任务
 	exec.Command("schtasks", "/delete", "/tn", windowsTaskName, "/f").Run()
 
 	// 创建新任务
-	cmd := exec.Command("schtasks", "/create", "/tn", windowsTaskName,
-		"/tr", scriptPath,
-		"/sc", "minute",
-		"/mo", fmt.Sprintf("%d", interval),
-		"/ru", "SYSTEM",
-		"/f")
+	command := job.command(false)
+	cmdArgs := []string{"/create", "/tn", windowsTaskName, "/tr", windowsCommandLine(command[0], command[1:])}
+	cmdArgs = append(cmdArgs, trigger...)
+	cmdArgs = append(cmdArgs, "/ru", "SYSTEM", "/f")
+	cmd := exec.Command("schtasks", cmdArgs...)
 
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("创建计划任务失败: %s, %v", string(output), err)
@@ -138,30 +98,96 @@ func (app *App) setupWindowsCron(interval int, scriptPath string) error {
 	return nil
 }
 
-// setupDarwinCron 设置 macOS 定时任务
-func (app *App) setupDarwinCron(interval int, scriptPath string) error {
+func (schtasksScheduler) remove() error {
+	if ok, _ := (schtasksScheduler{}).installed(); !ok {
+		return nil
+	}
+	if output, err := exec.Command("schtasks", "/delete", "/tn", windowsTaskName, "/f").CombinedOutput(); err != nil {
+		return fmt.Errorf("删除计划任务失败: %s, %v", string(output), err)
+	}
+	return nil
+}
+
+func (schtasksScheduler) installed() (bool, string) {
+	return exec.Command("schtasks", "/query", "/tn", windowsTaskName).Run() == nil, "schtasks " + windowsTaskName
+}
+
+// windowsCommandLine 拼接计划任务使用的命令行，为包含空格或引号的参数加引号
+func windowsCommandLine(exe string, args []string) string {
+	parts := []string{windowsQuote(exe)}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = windowsQuote(arg)
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// windowsQuote 按 CommandLineToArgvW 的规则为参数加引号：
+// 引号前的反斜杠加倍后再转义引号，结尾的反斜杠加倍以免吞掉收尾的引号
+func windowsQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			slashes++
+		case '"':
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// launchdScheduler macOS LaunchDaemon
+type launchdScheduler struct{}
+
+// launchdLabel LaunchDaemon 的标签
+const launchdLabel = "com.github.hosts"
+
+func (launchdScheduler) name() string { return schedulerLaunchd }
+
+func (launchdScheduler) available() bool { return commandExists("launchctl") }
+
+func (launchdScheduler) install(job scheduledJob) error {
+	trigger, err := launchdTrigger(job.schedule)
+	if err != nil {
+		return err
+	}
+
 	// 先尝试卸载已存在的服务
-	exec.Command("launchctl", "bootout", "system/com.github.hosts").Run()
+	exec.Command("launchctl", "bootout", "system/"+launchdLabel).Run()
 	// 删除旧的 plist 文件
 	os.Remove(darwinPlistPath)
 
+	var programArgs strings.Builder
+	for _, arg := range job.command(false) {
+		programArgs.WriteString("        <string>")
+		xml.EscapeText(&programArgs, []byte(arg))
+		programArgs.WriteString("</string>\n")
+	}
+
 	content := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
 <dict>
     <key>Label</key>
-    <string>com.github.hosts</string>
+    <string>%s</string>
     <key>ProgramArguments</key>
     <array>
-        <string>/bin/bash</string>
-        <string>%s</string>
-    </array>
-    <key>StartInterval</key>
-    <integer>%d</integer>
-    <key>RunAtLoad</key>
+%s    </array>
+%s    <key>RunAtLoad</key>
     <true/>
 </dict>
-</plist>`, scriptPath, interval*60)
+</plist>`, launchdLabel, programArgs.String(), trigger)
 
 	// 写入新的 plist 文件
 	if err := os.WriteFile(darwinPlistPath, []byte(content), 0644); err != nil {
@@ -177,31 +203,225 @@ func (app *App) setupDarwinCron(interval int, scriptPath string) error {
 	return nil
 }
 
-// setupLinuxCron 设置 Linux 定时任务
-func (app *App) setupLinuxCron(interval int, scriptPath string) error {
-	var schedule string
-	switch interval {
-	case 30:
-		schedule = "*/30 * * * *"
-	case 60:
-		schedule = "0 * * * *"
-	case 120:
-		schedule = "0 */2 * * *"
-	default:
-		return fmt.Errorf("无效的时间间隔: %d", interval)
+func (launchdScheduler) remove() error {
+	exec.Command("launchctl", "bootout", "system/"+launchdLabel).Run()
+	if err := os.Remove(darwinPlistPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (launchdScheduler) installed() (bool, string) {
+	return exec.Command("launchctl", "list", launchdLabel).Run() == nil, "launchd " + launchdLabel
+}
+
+// launchdTrigger 生成 plist 中的触发条件：固定间隔使用 StartInterval，其他使用 StartCalendarInterval
+func launchdTrigger(schedule ScheduleConfig) (string, error) {
+	if schedule.Kind == scheduleInterval {
+		return fmt.Sprintf("    <key>StartInterval</key>\n    <integer>%d</integer>\n", schedule.Interval*60), nil
 	}
 
-	content := fmt.Sprintf("%s root %s > %s/update.log 2>&1\n",
-		schedule, scriptPath, app.logDir)
+	var intervals []map[string]int
+	if schedule.Kind == scheduleDaily {
+		hour, minute, _ := parseTimeOfDay(schedule.At)
+		intervals = []map[string]int{{"Hour": hour, "Minute": minute}}
+	} else {
+		expr, err := parseCronExpr(schedule.Cron)
+		if err != nil {
+			return "", err
+		}
+		if intervals, err = expr.calendarIntervals(); err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("    <key>StartCalendarInterval</key>\n    <array>\n")
+	for _, interval := range intervals {
+		b.WriteString("        <dict>\n")
+		for _, key := range []string{"Month", "Day", "Weekday", "Hour", "Minute"} {
+			if value, ok := interval[key]; ok {
+				fmt.Fprintf(&b, "            <key>%s</key>\n            <integer>%d</integer>\n", key, value)
+			}
+		}
+		b.WriteString("        </dict>\n")
+	}
+	b.WriteString("    </array>\n")
+	return b.String(), nil
+}
+
+// cronServices 常见发行版中 cron 服务的名称
+var cronServices = []string{"cron", "crond", "cronie"}
+
+// cronDScheduler 写入 /etc/cron.d 的定时任务，适用于 Debian 系的 cron 和 RedHat 系的 cronie
+type cronDScheduler struct {
+	path string
+}
+
+func (s cronDScheduler) name() string { return schedulerCronD }
 
-	if err := os.WriteFile(linuxCronPath, []byte(content), 0644); err != nil {
+// available 需要 cron.d 目录和 cron 守护进程
+func (s cronDScheduler) available() bool {
+	if info, err := os.Stat(filepath.Dir(s.path)); err != nil || !info.IsDir() {
+		return false
+	}
+	return commandExists("cron") || commandExists("crond")
+}
+
+func (s cronDScheduler) install(job scheduledJob) error {
+	content, err := renderCronD(job)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, []byte(content), 0644); err != nil {
 		return fmt.Errorf("写入 cron 文件失败: %w", err)
 	}
 
-	// 重启 cron 服务
-	if err := exec.Command("systemctl", "restart", "cron").Run(); err != nil {
-		return fmt.Errorf("重启 cron 服务失败: %w", err)
+	// cron 会自动发现 cron.d 中的变化，这里只尽量让服务立即重新加载，服务名因发行版而异
+	if commandExists("systemctl") {
+		for _, service := range cronServices {
+			exec.Command("systemctl", "try-reload-or-restart", service).Run()
+		}
 	}
+	return nil
+}
 
+func (s cronDScheduler) remove() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
+
+func (s cronDScheduler) installed() (bool, string) {
+	_, err := os.Stat(s.path)
+	return err == nil, s.path
+}
+
+// renderCronD 生成 cron.d 文件内容，每个时间字段一行
+// 控制台输出已由程序写入日志文件，因此丢弃；标准错误仍交给 cron 处理，便于发现程序崩溃
+func renderCronD(job scheduledJob) (string, error) {
+	lines, err := job.schedule.cronLines()
+	if err != nil {
+		return "", err
+	}
+	command := shellCommand(job.command(false))
+	var content strings.Builder
+	for _, line := range lines {
+		fmt.Fprintf(&content, "%s root %s > /dev/null\n", line, command)
+	}
+	return content.String(), nil
+}
+
+// crontab 中标记本程序任务的注释
+const (
+	crontabBeginMarker = "# BEGIN github-hosts"
+	crontabEndMarker   = "# END github-hosts"
+)
+
+// crontabScheduler 写入 root 用户 crontab 的定时任务，用于没有 cron.d 的系统
+type crontabScheduler struct{}
+
+func (crontabScheduler) name() string { return schedulerCrontab }
+
+func (crontabScheduler) available() bool { return commandExists("crontab") }
+
+func (crontabScheduler) install(job scheduledJob) error {
+	cronLines, err := job.schedule.cronLines()
+	if err != nil {
+		return err
+	}
+	current, err := readCrontab()
+	if err != nil {
+		return err
+	}
+
+	command := shellCommand(job.command(false))
+	var block []string
+	block = append(block, crontabBeginMarker)
+	for _, line := range cronLines {
+		block = append(block, fmt.Sprintf("%s %s > /dev/null", line, command))
+	}
+	block = append(block, crontabEndMarker)
+
+	lines := append(stripCrontabBlock(current), block...)
+	return writeCrontab(strings.Join(lines, "\n") + "\n")
+}
+
+func (crontabScheduler) remove() error {
+	current, err := readCrontab()
+	if err != nil {
+		return err
+	}
+	lines := stripCrontabBlock(current)
+	if len(lines) == 0 {
+		return exec.Command("crontab", "-r").Run()
+	}
+	return writeCrontab(strings.Join(lines, "\n") + "\n")
+}
+
+func (crontabScheduler) installed() (bool, string) {
+	if !commandExists("crontab") {
+		return false, "crontab"
+	}
+	current, err := readCrontab()
+	if err != nil {
+		return false, "crontab"
+	}
+	return len(stripCrontabBlock(current)) != len(splitLines(current)), "crontab -l"
+}
+
+// readCrontab 读取当前用户的 crontab，没有 crontab 时返回空内容
+func readCrontab() ([]byte, error) {
+	output, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		// crontab -l 在没有任务时以非零状态退出
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取 crontab 失败: %w", err)
+	}
+	return output, nil
+}
+
+// writeCrontab 替换当前用户的 crontab
+func writeCrontab(content string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(content)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("写入 crontab 失败: %s, %v", string(output), err)
+	}
+	return nil
+}
+
+// stripCrontabBlock 返回去掉本程序任务后的 crontab 行
+func stripCrontabBlock(content []byte) []string {
+	var lines []string
+	inBlock := false
+	for _, line := range splitLines(content) {
+		switch {
+		case strings.TrimSpace(line) == crontabBeginMarker:
+			inBlock = true
+		case strings.TrimSpace(line) == crontabEndMarker:
+			inBlock = false
+		case !inBlock:
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// shellCommand 拼接 cron 使用的命令行
+func shellCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote 为 cron 命令行中的参数加单引号；% 在 cron 中表示换行，需要转义
+func shellQuote(s string) string {
+	s = strings.ReplaceAll(s, "'", `'\''`)
+	return "'" + strings.ReplaceAll(s, "%", `\%`) + "'"
+}
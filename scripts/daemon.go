@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// 守护进程状态
+const (
+	daemonRunning  = "running"  // 正常运行，等待下一次更新
+	daemonUpdating = "updating" // 正在更新
+	daemonBackoff  = "backoff"  // 连续失败，按退避时间重试
+	daemonStopped  = "stopped"  // 已正常退出
+)
+
+const (
+	// daemonBackoffBase 第一次失败后的重试等待时间，之后每次翻倍
+	daemonBackoffBase = time.Minute
+	// daemonBackoffMax 重试等待时间的上限
+	daemonBackoffMax = time.Hour
+)
+
+// DaemonState 守护进程写入的状态文件
+type DaemonState struct {
+	PID                 int       `json:"pid"`
+	Status              string    `json:"status"`
+	Schedule            string    `json:"schedule"`
+	Started             time.Time `json:"started"`
+	Updated             time.Time `json:"updated"` // 状态文件最后写入的时间
+	LastRun             time.Time `json:"lastRun,omitempty"`
+	LastSuccess         time.Time `json:"lastSuccess,omitempty"`
+	LastError           string    `json:"lastError,omitempty"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	NextRun             time.Time `json:"nextRun,omitempty"`
+}
+
+// loadDaemonState 读取守护进程状态文件
+func (app *App) loadDaemonState() (*DaemonState, error) {
+	data, err := os.ReadFile(app.daemonFile)
+	if err != nil {
+		return nil, err
+	}
+	var state DaemonState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("守护进程状态文件格式无效: %w", err)
+	}
+	return &state, nil
+}
+
+// saveDaemonState 以原子方式写入守护进程状态文件
+func (app *App) saveDaemonState(state *DaemonState) error {
+	state.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(state, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmp := app.daemonFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, app.daemonFile); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// daemonBackoffDelay 返回连续失败 failures 次后的重试等待时间
+func daemonBackoffDelay(failures int) time.Duration {
+	delay := daemonBackoffBase
+	for i := 1; i < failures && delay < daemonBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > daemonBackoffMax {
+		delay = daemonBackoffMax
+	}
+	return delay
+}
+
+// runDaemon 在前台循环执行更新，不使用系统的定时任务
+// 启动后立即更新一次，之后按配置的更新计划执行；SIGHUP 重新读取配置，SIGTERM/SIGINT 退出
+func (app *App) runDaemon() error {
+	if err := app.setupDirectories(); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	// 同一时间只允许一个守护进程：整个运行期间持有 daemon.lock，进程退出时锁随之释放
+	unlock, err := tryLockFile(filepath.Join(app.baseDir, "daemon.lock"))
+	if err != nil {
+		if !errors.Is(err, errLocked) {
+			return fmt.Errorf("获取守护进程锁失败: %w", err)
+		}
+		if previous, err := app.loadDaemonState(); err == nil && previous.PID != os.Getpid() {
+			return fmt.Errorf("守护进程已在运行（PID %d）", previous.PID)
+		}
+		return fmt.Errorf("守护进程已在运行")
+	}
+	defer unlock()
+
+	if backend, detail := activeScheduler(); backend != nil {
+		app.logWithLevel(WARNING, "系统定时任务（%s: %s）也已安装，可能重复更新，可通过 config set autoUpdate false 移除", backend.name(), detail)
+	}
+
+	app.unattended = true
+	app.trigger = triggerDaemon
+	config, err := app.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	schedule := config.schedule()
+
+	state := &DaemonState{
+		PID:      os.Getpid(),
+		Status:   daemonRunning,
+		Schedule: schedule.String(),
+		Started:  time.Now().UTC(),
+	}
+	defer func() {
+		state.Status = daemonStopped
+		state.NextRun = time.Time{}
+		app.saveDaemonState(state)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	app.logWithLevel(INFO, "守护进程已启动（PID %d），更新计划: %s", state.PID, schedule)
+	next := time.Now()
+	for {
+		state.NextRun = next.UTC()
+		if err := app.saveDaemonState(state); err != nil {
+			app.logWithLevel(WARNING, "写入守护进程状态失败: %v", err)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case sig := <-signals:
+			timer.Stop()
+			if sig != syscall.SIGHUP {
+				app.logWithLevel(INFO, "收到 %v 信号，守护进程退出", sig)
+				return nil
+			}
+			// 重新读取配置，按新的更新计划计算下一次执行时间，并按新的日志配置重新打开日志文件
+			app.closeLog()
+			config, err := app.loadConfig()
+			if err != nil {
+				app.logWithLevel(WARNING, "重新读取配置失败，继续使用原配置: %v", err)
+				continue
+			}
+			newSchedule := config.schedule()
+			if state.ConsecutiveFailures == 0 && !state.LastRun.IsZero() {
+				newNext, err := app.nextDaemonRun(newSchedule, time.Now())
+				if err != nil {
+					app.logWithLevel(WARNING, "新的更新计划无效，继续使用原配置: %v", err)
+					continue
+				}
+				next = newNext
+			}
+			schedule = newSchedule
+			state.Schedule = schedule.String()
+			app.logWithLevel(INFO, "已重新读取配置，更新计划: %s，下次更新: %s", schedule, next.Format("2006-01-02 15:04:05"))
+			continue
+		case <-timer.C:
+		}
+
+		state.Status = daemonUpdating
+		state.LastRun = time.Now().UTC()
+		app.saveDaemonState(state)
+
+		if err := app.updateHosts(); err != nil {
+			state.ConsecutiveFailures++
+			state.LastError = err.Error()
+			state.Status = daemonBackoff
+			delay := daemonBackoffDelay(state.ConsecutiveFailures)
+			next = time.Now().Add(delay)
+			app.logWithLevel(ERROR, "更新 hosts 失败（连续 %d 次）: %v，%s 后重试", state.ConsecutiveFailures, err, delay)
+			continue
+		}
+
+		state.ConsecutiveFailures = 0
+		state.LastError = ""
+		state.LastSuccess = time.Now().UTC()
+		state.Status = daemonRunning
+		if next, err = app.nextDaemonRun(schedule, time.Now()); err != nil {
+			return err
+		}
+		app.logWithLevel(SUCCESS, "hosts 文件更新完成，下次更新: %s", next.Format("2006-01-02 15:04:05"))
+	}
+}
+
+// nextDaemonRun 返回下一次更新的时间，包含随机延迟
+func (app *App) nextDaemonRun(schedule ScheduleConfig, now time.Time) (time.Time, error) {
+	next, err := schedule.next(now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if schedule.Jitter > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(schedule.Jitter) * int64(time.Minute))))
+	}
+	return next, nil
+}
+
+// daemonReport 读取守护进程状态，没有状态文件时返回 nil
+func (app *App) daemonReport() *DaemonReport {
+	state, err := app.loadDaemonState()
+	if err != nil {
+		return nil
+	}
+	report := &DaemonReport{DaemonState: state}
+	report.Alive = state.Status != daemonStopped && processAlive(state.PID)
+	report.Healthy = report.Alive && state.ConsecutiveFailures == 0
+	return report
+}
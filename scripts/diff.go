@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
+)
+
+// 可比较的内容
+const (
+	diffRefCurrent  = "current"  // 当前的 hosts 文件
+	diffRefIncoming = "incoming" // 从数据源获取的新数据生成的 hosts 内容
+)
+
+// diffContextLines 文本差异中每处修改前后显示的行数
+const diffContextLines = 3
+
+// diffMaxEditCost 搜索最短编辑路径的最大步数
+// 超过后不再寻找最短差异，直接把剩余部分作为整体删除和新增，避免两份完全不同的大文件耗时过长
+const diffMaxEditCost = 1000
+
+// diffOp 逐行差异中的一行，kind 为 ' '（相同）、'-'（删除）或 '+'（新增）
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// splitLines 将内容拆分为行，忽略末尾的换行符
+func splitLines(content []byte) []string {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines 计算两组行之间的差异
+// 先去掉相同的开头和结尾，再用线性空间的 Myers 算法（二分查找中间蛇形路径）比较剩余部分，
+// 大型 hosts 文件（如含数十万行的广告过滤列表）也不会占用过多内存
+func diffLines(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	return appendDiff(ops, a, b)
+}
+
+// appendDiff 将 a 到 b 的差异追加到 ops
+func appendDiff(ops []diffOp, a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	for _, text := range a[:prefix] {
+		ops = append(ops, diffOp{' ', text})
+	}
+	ops = appendMiddleDiff(ops, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for _, text := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', text})
+	}
+	return ops
+}
+
+// appendMiddleDiff 比较首尾均不相同的两组行
+func appendMiddleDiff(ops []diffOp, a, b []string) []diffOp {
+	if len(a) == 0 || len(b) == 0 {
+		for _, text := range a {
+			ops = append(ops, diffOp{'-', text})
+		}
+		for _, text := range b {
+			ops = append(ops, diffOp{'+', text})
+		}
+		return ops
+	}
+
+	if x, y, ok := middleSnake(a, b); ok {
+		ops = appendDiff(ops, a[:x], b[:y])
+		return appendDiff(ops, a[x:], b[y:])
+	}
+	for _, text := range a {
+		ops = append(ops, diffOp{'-', text})
+	}
+	for _, text := range b {
+		ops = append(ops, diffOp{'+', text})
+	}
+	return ops
+}
+
+// middleSnake 同时从两端搜索最短编辑路径，返回两端路径相遇的位置
+// 只保存每条对角线上到达的最远位置，空间为 O(len(a)+len(b))
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	size := 2*maxD + 2
+
+	// forward[k] 为正向搜索在对角线 k = x - y 上到达的最远 x，backward 为从末尾反向搜索的对应值
+	forward := make([]int, size)
+	backward := make([]int, size)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	// 两端编辑距离之差为奇数时在正向搜索中检查相遇，否则在反向搜索中检查
+	front := delta%2 != 0
+	var k1start, k1end, k2start, k2end int
+	for d := 0; d < maxD && d < diffMaxEditCost; d++ {
+		for k1 := -d + k1start; k1 <= d-k1end; k1 += 2 {
+			i := offset + k1
+			var x1 int
+			if k1 == -d || (k1 != d && forward[i-1] < forward[i+1]) {
+				x1 = forward[i+1]
+			} else {
+				x1 = forward[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			forward[i] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				j := offset + delta - k1
+				if j >= 0 && j < size && backward[j] != -1 && x1 >= n-backward[j] {
+					return x1, y1, true
+				}
+			}
+		}
+
+		for k2 := -d + k2start; k2 <= d-k2end; k2 += 2 {
+			i := offset + k2
+			var x2 int
+			if k2 == -d || (k2 != d && backward[i-1] < backward[i+1]) {
+				x2 = backward[i+1]
+			} else {
+				x2 = backward[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			backward[i] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				j := offset + delta - k2
+				if j >= 0 && j < size && forward[j] != -1 {
+					x1 := forward[j]
+					y1 := offset + x1 - j
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// unifiedDiff 生成统一格式的文本差异，内容相同时返回空字符串
+func unifiedDiff(aName, bName string, a, b []byte) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// aPos[k]、bPos[k] 为第 k 个操作之前两侧已经过的行数
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	var changes []int
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+		if op.kind != ' ' {
+			changes = append(changes, k)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+
+	for c := 0; c < len(changes); {
+		// 相距不超过两倍上下文的修改合并到同一段
+		last := c
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*diffContextLines {
+			last++
+		}
+		start := changes[c] - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := changes[last] + diffContextLines + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		aCount := aPos[end] - aPos[start]
+		bCount := bPos[end] - bPos[start]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aPos[start], aCount), hunkRange(bPos[start], bCount))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		c = last + 1
+	}
+	return out.String()
+}
+
+// hunkRange 返回差异段头部的行号范围
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// 记录变化类型
+const (
+	entryAdded   = "added"
+	entryRemoved = "removed"
+	entryChanged = "changed"
+)
+
+// entryChange 单个域名在管理区块中的变化
+type entryChange struct {
+	Domain string `json:"domain"`
+	Kind   string `json:"kind"`
+	OldIP  string `json:"oldIp,omitempty"`
+	NewIP  string `json:"newIp,omitempty"`
+}
+
+// entryIPs 按域名汇总记录，一个域名有多个 IP 时以逗号连接
+func entryIPs(entries []HostEntry) map[string]string {
+	ips := make(map[string][]string)
+	for _, e := range entries {
+		if !containsString(ips[e.Domain], e.IP) {
+			ips[e.Domain] = append(ips[e.Domain], e.IP)
+		}
+	}
+	result := make(map[string]string, len(ips))
+	for domain, list := range ips {
+		sort.Strings(list)
+		result[domain] = strings.Join(list, ",")
+	}
+	return result
+}
+
+// diffEntries 按域名比较两组记录，结果按域名排序
+func diffEntries(from, to []HostEntry) []entryChange {
+	old, cur := entryIPs(from), entryIPs(to)
+
+	var changes []entryChange
+	for domain, oldIP := range old {
+		newIP, ok := cur[domain]
+		switch {
+		case !ok:
+			changes = append(changes, entryChange{Domain: domain, Kind: entryRemoved, OldIP: oldIP})
+		case newIP != oldIP:
+			changes = append(changes, entryChange{Domain: domain, Kind: entryChanged, OldIP: oldIP, NewIP: newIP})
+		}
+	}
+	for domain, newIP := range cur {
+		if _, ok := old[domain]; !ok {
+			changes = append(changes, entryChange{Domain: domain, Kind: entryAdded, NewIP: newIP})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Domain < changes[j].Domain
+	})
+	return changes
+}
+
+// printEntryChanges 输出按域名的变化
+func printEntryChanges(changes []entryChange) {
+	if len(changes) == 0 {
+		fmt.Println("GitHub Hosts 记录没有变化")
+		return
+	}
+
+	var added, removed, changed int
+	for _, c := range changes {
+		switch c.Kind {
+		case entryAdded:
+			added++
+			fmt.Printf("+ %-45s %s\n", c.Domain, c.NewIP)
+		case entryRemoved:
+			removed++
+			fmt.Printf("- %-45s %s\n", c.Domain, c.OldIP)
+		case entryChanged:
+			changed++
+			fmt.Printf("~ %-45s %s → %s\n", c.Domain, c.OldIP, c.NewIP)
+		}
+	}
+	fmt.Printf("\n共 %d 处变化：新增 %d，删除 %d，修改 %d\n", len(changes), added, removed, changed)
+}
+
+// showDiff 输出两份 hosts 内容之间按域名的变化和文本差异
+// full 为 false 时文本差异只比较 GitHub Hosts 管理区块，不逐行比较用户自己的内容
+func showDiff(fromName, toName string, from, to []byte, full bool) {
+	fromFile, toFile := hostsfile.Parse(from), hostsfile.Parse(to)
+	fmt.Printf("\n=== 记录变化（%s → %s）===\n", fromName, toName)
+	printEntryChanges(diffEntries(fromFile.Entries(), toFile.Entries()))
+
+	if full {
+		fmt.Println("\n=== 文本差异（完整文件）===")
+	} else {
+		fmt.Println("\n=== 文本差异（管理区块）===")
+		from, to = fromFile.Block(), toFile.Block()
+	}
+	if text := unifiedDiff(fromName, toName, from, to); text != "" {
+		fmt.Print(text)
+	} else {
+		fmt.Println("内容完全相同")
+	}
+}
+
+// loadDiffSide 读取要比较的一方：current、incoming 或备份序号/文件名
+func (app *App) loadDiffSide(ref string) (string, []byte, error) {
+	switch ref {
+	case diffRefCurrent:
+		content, err := os.ReadFile(hostsFile)
+		if err != nil {
+			return "", nil, fmt.Errorf("读取 hosts 文件失败: %w", err)
+		}
+		return diffRefCurrent, content, nil
+	case diffRefIncoming:
+		config, err := app.loadConfigOrDefault()
+		if err != nil {
+			return "", nil, err
+		}
+		content, _, err := app.prepareUpdate(config)
+		if err != nil {
+			return "", nil, err
+		}
+		return diffRefIncoming, content, nil
+	default:
+		path, err := app.resolveBackup(ref)
+		if err != nil {
+			return "", nil, err
+		}
+		content, err := readBackup(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("读取备份失败: %w", err)
+		}
+		return filepath.Base(path), content, nil
+	}
+}
+
+// diffRefs 比较两份 hosts 内容，full 为 true 时文本差异包括整个文件
+func (app *App) diffRefs(fromRef, toRef string, full bool) error {
+	fromName, from, err := app.loadDiffSide(fromRef)
+	if err != nil {
+		return err
+	}
+	toName, to, err := app.loadDiffSide(toRef)
+	if err != nil {
+		return err
+	}
+	showDiff(fromName, toName, from, to, full)
+	return nil
+}
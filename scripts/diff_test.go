@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// diffScript 将差异写成紧凑的形式，例如 " a", "-b", "+c"
+func diffScript(ops []diffOp) []string {
+	script := make([]string, len(ops))
+	for i, op := range ops {
+		script[i] = string(op.kind) + op.text
+	}
+	return script
+}
+
+// applyDiff 从差异中还原两侧的内容
+func applyDiff(ops []diffOp) (a, b []string) {
+	for _, op := range ops {
+		if op.kind != '+' {
+			a = append(a, op.text)
+		}
+		if op.kind != '-' {
+			b = append(b, op.text)
+		}
+	}
+	return a, b
+}
+
+// lcsLength 用动态规划计算最长公共子序列的长度，用于检查差异是否最短
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want []string
+	}{
+		{"两侧都为空", nil, nil, []string{}},
+		{"原内容为空", nil, []string{"a", "b"}, []string{"+a", "+b"}},
+		{"新内容为空", []string{"a", "b"}, nil, []string{"-a", "-b"}},
+		{"内容相同", []string{"a", "b", "c"}, []string{"a", "b", "c"}, []string{" a", " b", " c"}},
+		{"中间新增一行", []string{"a", "c"}, []string{"a", "b", "c"}, []string{" a", "+b", " c"}},
+		{"开头新增一行", []string{"b"}, []string{"a", "b"}, []string{"+a", " b"}},
+		{"中间删除一行", []string{"a", "b", "c"}, []string{"a", "c"}, []string{" a", "-b", " c"}},
+		{"末尾删除一行", []string{"a", "b"}, []string{"a"}, []string{" a", "-b"}},
+		{"修改一行", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []string{" a", "-b", "+x", " c"}},
+		{"完全不同", []string{"a"}, []string{"b"}, []string{"-a", "+b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffScript(diffLines(tt.a, tt.b)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	// 用很小的字母表生成大量重复行，覆盖中间蛇形路径的各种情况
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func(n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 5000; i++ {
+		a, b := randomLines(rng.Intn(30)), randomLines(rng.Intn(30))
+		ops := diffLines(a, b)
+
+		gotA, gotB := applyDiff(ops)
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) = %q does not rebuild both sides", a, b, diffScript(ops))
+		}
+
+		same := 0
+		for _, op := range ops {
+			if op.kind == ' ' {
+				same++
+			}
+		}
+		if want := lcsLength(a, b); same != want {
+			t.Fatalf("diffLines(%q, %q) keeps %d lines, want %d", a, b, same, want)
+		}
+	}
+}
+
+func TestDiffLinesMaxEditCost(t *testing.T) {
+	// 完全不同的大文件超过搜索步数后整体删除和新增，结果仍能还原两侧
+	a := make([]string, 3*diffMaxEditCost)
+	b := make([]string, 3*diffMaxEditCost)
+	for i := range a {
+		a[i] = fmt.Sprintf("a%d", i)
+		b[i] = fmt.Sprintf("b%d", i)
+	}
+	a[len(a)/2] = "common"
+	b[len(b)/2] = "common"
+
+	gotA, gotB := applyDiff(diffLines(a, b))
+	if !reflect.DeepEqual(gotA, a) || !reflect.DeepEqual(gotB, b) {
+		t.Fatal("diffLines() of large inputs does not rebuild both sides")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line%d", i))
+	}
+	from := strings.Join(lines, "\n") + "\n"
+	changed := append([]string{}, lines...)
+	changed[1] = "changed2"
+	changed[17] = "changed18"
+	to := strings.Join(changed, "\r\n") + "\r\n"
+
+	want := "--- a\n+++ b\n" +
+		"@@ -1,5 +1,5 @@\n line1\n-line2\n+changed2\n line3\n line4\n line5\n" +
+		"@@ -15,6 +15,6 @@\n line15\n line16\n line17\n-line18\n+changed18\n line19\n line20\n"
+	if got := unifiedDiff("a", "b", []byte(from), []byte(to)); got != want {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, want)
+	}
+
+	// 换行符不同但内容相同
+	if got := unifiedDiff("a", "b", []byte(from), []byte(strings.Join(lines, "\r\n"))); got != "" {
+		t.Errorf("unifiedDiff() of identical content = %q", got)
+	}
+	// 新增到空文件
+	if got := unifiedDiff("a", "b", nil, []byte("x\n")); got != "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+x\n" {
+		t.Errorf("unifiedDiff() from empty = %q", got)
+	}
+}
+
+func TestDiffEntries(t *testing.T) {
+	from := []HostEntry{
+		{IP: "1.1.1.1", Domain: "github.com"},
+		{IP: "2.2.2.2", Domain: "api.github.com"},
+		{IP: "3.3.3.3", Domain: "gist.github.com"},
+	}
+	to := []HostEntry{
+		{IP: "1.1.1.1", Domain: "github.com"},
+		{IP: "4.4.4.4", Domain: "api.github.com"},
+		{IP: "5.5.5.5", Domain: "raw.githubusercontent.com"},
+	}
+	want := []entryChange{
+		{Domain: "api.github.com", Kind: entryChanged, OldIP: "2.2.2.2", NewIP: "4.4.4.4"},
+		{Domain: "gist.github.com", Kind: entryRemoved, OldIP: "3.3.3.3"},
+		{Domain: "raw.githubusercontent.com", Kind: entryAdded, NewIP: "5.5.5.5"},
+	}
+	if got := diffEntries(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffEntries() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
+)
+
+// 默认的更新后健康检查配置
+const (
+	defaultGuardMaxFailurePercent = 50
+	defaultGuardProbe             = connProbeTLS
+)
+
+// guardCheckTimeout 健康检查的总时限；检查期间持有 hosts 锁，须明显短于其他进程等待锁的时间
+const guardCheckTimeout = hostsLockTimeout * 2 / 3
+
+// defaultGuardDomains 默认参与健康检查的关键域名
+var defaultGuardDomains = []string{
+	"github.com",
+	"api.github.com",
+	"raw.githubusercontent.com",
+}
+
+// GuardConfig 更新后健康检查配置
+// 启用后，写入新记录后会测试关键域名，失败比例超过阈值时自动恢复更新前的备份
+type GuardConfig struct {
+	Enabled           bool     `json:"enabled"`
+	Domains           []string `json:"domains,omitempty"`           // 检查的域名，未配置时使用默认列表
+	Probe             string   `json:"probe,omitempty"`             // tcp, tls 或 http，未配置时使用 tls
+	MaxFailurePercent int      `json:"maxFailurePercent,omitempty"` // 允许的失败比例（%），超过时回滚，0 表示使用默认值
+	Timeout           int      `json:"timeout,omitempty"`           // 单个域名的测试时限（秒），0 表示使用默认值
+}
+
+// errRolledBack 表示更新因健康检查未通过而被回滚
+var errRolledBack = errors.New("已自动回滚")
+
+// RollbackRecord 最近一次自动回滚的记录
+type RollbackRecord struct {
+	Time          time.Time `json:"time"`
+	Backup        string    `json:"backup"`           // 恢复的备份文件名
+	Source        string    `json:"source,omitempty"` // 被回滚的更新所使用的数据源
+	Failed        int       `json:"failed"`
+	Total         int       `json:"total"`
+	FailedDomains []string  `json:"failedDomains"`
+}
+
+// guard 返回健康检查配置，未配置的项使用默认值
+func (c *Config) guard() GuardConfig {
+	var g GuardConfig
+	if c != nil && c.Guard != nil {
+		g = *c.Guard
+	}
+	if len(g.Domains) == 0 {
+		g.Domains = defaultGuardDomains
+	}
+	if g.Probe == "" {
+		g.Probe = defaultGuardProbe
+	}
+	if g.MaxFailurePercent <= 0 {
+		g.MaxFailurePercent = defaultGuardMaxFailurePercent
+	}
+	return g
+}
+
+// connTestOptions 返回健康检查使用的测试参数
+func (g GuardConfig) connTestOptions() connTestOptions {
+	opts := defaultConnTestOptions()
+	opts.probe = g.Probe
+	if g.Timeout > 0 {
+		opts.timeout = time.Duration(g.Timeout) * time.Second
+	}
+	return opts
+}
+
+// validateGuardProbe 检查健康检查的探测方式，健康检查需要实际连接，不支持 dns
+func validateGuardProbe(probe string) error {
+	switch probe {
+	case connProbeTCP, connProbeTLS, connProbeHTTP:
+		return nil
+	default:
+		return fmt.Errorf("无效的健康检查方式: %s（可选 tcp, tls, http）", probe)
+	}
+}
+
+// setGuard 修改健康检查配置中的一项，key 为 enabled、probe 或 maxFailurePercent
+func (app *App) setGuard(key, value string) error {
+	err := app.modifyConfig(func(config *Config) error {
+		var guard GuardConfig
+		if config.Guard != nil {
+			guard = *config.Guard
+		}
+		switch key {
+		case "enabled":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("guard.enabled 必须是 true 或 false")
+			}
+			guard.Enabled = enabled
+		case "probe":
+			if err := validateGuardProbe(value); err != nil {
+				return err
+			}
+			guard.Probe = value
+		case "maxFailurePercent":
+			percent, err := strconv.Atoi(value)
+			if err != nil || percent < 1 || percent > 100 {
+				return fmt.Errorf("guard.maxFailurePercent 必须是 1 到 100 之间的整数")
+			}
+			guard.MaxFailurePercent = percent
+		default:
+			return fmt.Errorf("未知的健康检查配置项: %s", key)
+		}
+		config.Guard = &guard
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.logWithLevel(SUCCESS, "健康检查配置 %s 已修改为 %s", key, value)
+	return nil
+}
+
+// guardFailed 判断单个域名是否未通过健康检查
+// 只看经 hosts 记录中的 IP 能否正常连接；系统解析结果可能仍受缓存影响，不作为回滚依据
+func guardFailed(result DomainTestResult) bool {
+	switch result.Status {
+	case "connect_failed", "tls_failed", "cert_invalid", "http_status":
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRollback 判断失败比例是否超过阈值；没有可测试的域名时不回滚
+func (g GuardConfig) shouldRollback(failed, total int) bool {
+	return total > 0 && failed*100 > g.MaxFailurePercent*total
+}
+
+// checkHealth 并发测试新内容中关键域名的记录，返回未通过的域名和参与测试的域名数
+// 不在管理区块中的域名跳过；所有测试共用 guardCheckTimeout 的总时限，到期仍未完成的域名视为未通过
+func (app *App) checkHealth(guard GuardConfig, content []byte) ([]string, int) {
+	entries := make(map[string]HostEntry)
+	for _, e := range hostsfile.Parse(content).Entries() {
+		if _, ok := entries[e.Domain]; !ok {
+			entries[e.Domain] = e
+		}
+	}
+
+	var tests []HostEntry
+	for _, domain := range guard.Domains {
+		entry, ok := entries[domain]
+		if !ok {
+			app.logWithLevel(WARNING, "健康检查: %s 不在 GitHub Hosts 记录中，已跳过", domain)
+			continue
+		}
+		tests = append(tests, entry)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), guardCheckTimeout)
+	defer cancel()
+	tester := newConnTester(guard.connTestOptions())
+	results := make([]DomainTestResult, len(tests))
+	var wg sync.WaitGroup
+	for i, entry := range tests {
+		wg.Add(1)
+		go func(i int, entry HostEntry) {
+			defer wg.Done()
+			results[i] = tester.testContext(ctx, entry)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, result := range results {
+		if guardFailed(result) {
+			app.logWithLevel(WARNING, "健康检查: %s (%s) 未通过: %s", tests[i].Domain, tests[i].IP, result.Error)
+			failed = append(failed, tests[i].Domain)
+		} else {
+			app.logWithLevel(INFO, "健康检查: %s (%s) 正常", tests[i].Domain, tests[i].IP)
+		}
+	}
+	return failed, len(tests)
+}
+
+// guardUpdate 在写入新内容后执行健康检查，失败比例超过阈值时恢复更新前的备份
+// 调用方须持有 hosts 文件锁；发生回滚时返回错误
+func (app *App) guardUpdate(config *Config, content []byte, backupName, source string) error {
+	guard := config.guard()
+	if !guard.Enabled {
+		return nil
+	}
+
+	app.logWithLevel(INFO, "正在执行更新后健康检查（%s）", guard.Probe)
+	failed, total := app.checkHealth(guard, content)
+	if total == 0 {
+		app.logWithLevel(WARNING, "健康检查没有可测试的域名，已跳过")
+		return nil
+	}
+	if !guard.shouldRollback(len(failed), total) {
+		app.logWithLevel(SUCCESS, "健康检查通过（%d/%d 正常）", total-len(failed), total)
+		return nil
+	}
+
+	app.logWithLevel(ERROR, "健康检查未通过（%d/%d 失败，阈值 %d%%），正在恢复更新前的备份 %s",
+		len(failed), total, guard.MaxFailurePercent, backupName)
+	previous, err := readBackup(filepath.Join(app.backupDir, backupName))
+	if err != nil {
+		return fmt.Errorf("健康检查未通过，读取更新前的备份失败: %w", err)
+	}
+	if err := writeHostsFile(hostsFile, previous); err != nil {
+		return fmt.Errorf("健康检查未通过，恢复更新前的备份失败: %w", err)
+	}
+	if err := app.flushDNSCache(); err != nil {
+		app.logWithLevel(WARNING, "DNS 缓存刷新失败: %v", err)
+	}
+
+	app.recordRollback(&RollbackRecord{
+		Time:          time.Now().UTC(),
+		Backup:        backupName,
+		Source:        source,
+		Failed:        len(failed),
+		Total:         total,
+		FailedDomains: failed,
+	})
+	app.logWithLevel(WARNING, "已回滚到更新前的 hosts 文件")
+	return fmt.Errorf("更新后健康检查未通过（%d/%d 失败），%w", len(failed), total, errRolledBack)
+}
+
+// recordRollback 在配置中记录最近一次回滚
+func (app *App) recordRollback(record *RollbackRecord) {
+	err := app.modifyConfig(func(config *Config) error {
+		config.LastRollback = record
+		return nil
+	})
+	if err != nil {
+		app.logWithLevel(WARNING, "记录回滚失败: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGuardShouldRollback(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxPercent int
+		failed     int
+		total      int
+		want       bool
+	}{
+		{"全部通过", 50, 0, 3, false},
+		{"低于阈值", 50, 1, 3, false},
+		{"等于阈值不回滚", 50, 2, 4, false},
+		{"超过阈值", 50, 2, 3, true},
+		{"全部失败", 50, 3, 3, true},
+		{"阈值 100 时从不回滚", 100, 3, 3, false},
+		{"阈值 1 时任一失败即回滚", 1, 1, 3, true},
+		{"没有可测试的域名", 50, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := GuardConfig{MaxFailurePercent: tt.maxPercent}
+			if got := g.shouldRollback(tt.failed, tt.total); got != tt.want {
+				t.Errorf("shouldRollback(%d, %d) with %d%% = %v, want %v", tt.failed, tt.total, tt.maxPercent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuardFailed(t *testing.T) {
+	tests := map[string]bool{
+		"ok":             false,
+		"connect_failed": true,
+		"tls_failed":     true,
+		"cert_invalid":   true,
+		"http_status":    true,
+		// 系统解析可能仍受缓存影响，不作为回滚依据
+		"dns_failed":  false,
+		"ip_mismatch": false,
+	}
+	for status, want := range tests {
+		if got := guardFailed(DomainTestResult{Status: status}); got != want {
+			t.Errorf("guardFailed(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestConfigGuardDefaults(t *testing.T) {
+	var config *Config
+	g := config.guard()
+	if !reflect.DeepEqual(g.Domains, defaultGuardDomains) || g.Probe != defaultGuardProbe ||
+		g.MaxFailurePercent != defaultGuardMaxFailurePercent || g.Enabled {
+		t.Errorf("nil config guard() = %+v", g)
+	}
+
+	config = &Config{Guard: &GuardConfig{Enabled: true, Domains: []string{"github.com"}, Probe: connProbeHTTP, MaxFailurePercent: 20}}
+	want := GuardConfig{Enabled: true, Domains: []string{"github.com"}, Probe: connProbeHTTP, MaxFailurePercent: 20}
+	if g := config.guard(); !reflect.DeepEqual(g, want) {
+		t.Errorf("guard() = %+v, want %+v", g, want)
+	}
+}
+
+func TestGuardCheckTimeout(t *testing.T) {
+	// 健康检查在 hosts 锁内进行，总时限必须短于其他进程等待锁的时间
+	if guardCheckTimeout >= hostsLockTimeout {
+		t.Errorf("guardCheckTimeout = %s, want less than hostsLockTimeout %s", guardCheckTimeout, hostsLockTimeout)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 更新的触发方式
+const (
+	triggerManual    = "manual"    // 交互菜单或手动执行 update
+	triggerScheduled = "scheduled" // 系统定时任务调用 update --unattended
+	triggerDaemon    = "daemon"    // 内置守护进程
+)
+
+// defaultHistoryTail history 默认显示的条数
+const defaultHistoryTail = 20
+
+// HistoryRecord 更新历史中的一条记录，每次尝试更新写入一条
+type HistoryRecord struct {
+	ID         string        `json:"id"` // 与日志中的运行 ID 相同
+	Time       time.Time     `json:"time"`
+	Trigger    string        `json:"trigger"`
+	Source     string        `json:"source,omitempty"`
+	DurationMs int64         `json:"durationMs"`
+	Result     string        `json:"result"` // success, failed 或 rolled_back
+	Error      string        `json:"error,omitempty"`
+	Backup     string        `json:"backup,omitempty"` // 更新前的备份文件名
+	Entries    int           `json:"entries"`          // 写入的记录数
+	Changes    []entryChange `json:"changes"`          // 按域名的 IP 变化，回滚的更新记录的是被撤销的变化
+}
+
+// HistoryReport history --output json 的输出
+type HistoryReport struct {
+	reportHeader
+	Records []HistoryRecord `json:"records"`
+}
+
+// HistoryStatus 状态报告中的更新历史摘要
+type HistoryStatus struct {
+	LastAttempt *HistoryRecord `json:"lastAttempt,omitempty"`
+	LastSuccess *HistoryRecord `json:"lastSuccess,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// historyQuery 更新历史的查询条件
+type historyQuery struct {
+	since   time.Time // 为零时不限制
+	until   time.Time // 为零时不限制
+	result  string    // 为空时不限制
+	trigger string    // 为空时不限制
+	domain  string    // 只看该域名有变化的记录
+	tail    int       // 只显示最后 N 条，0 表示全部
+}
+
+// match 判断记录是否满足条件
+func (q historyQuery) match(r HistoryRecord) bool {
+	if !q.since.IsZero() && r.Time.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && !r.Time.Before(q.until) {
+		return false
+	}
+	if q.result != "" && r.Result != q.result {
+		return false
+	}
+	if q.trigger != "" && r.Trigger != q.trigger {
+		return false
+	}
+	if q.domain != "" {
+		for _, c := range r.Changes {
+			if c.Domain == q.domain {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// validateHistoryResult 检查查询条件中的更新结果
+func validateHistoryResult(result string) error {
+	switch result {
+	case "", runOutcomeSuccess, runOutcomeFailed, runOutcomeRolledBack:
+		return nil
+	default:
+		return fmt.Errorf("无效的更新结果: %s（可选 success, failed, rolled_back）", result)
+	}
+}
+
+// validateHistoryTrigger 检查查询条件中的触发方式
+func validateHistoryTrigger(trigger string) error {
+	switch trigger {
+	case "", triggerManual, triggerScheduled, triggerDaemon:
+		return nil
+	default:
+		return fmt.Errorf("无效的触发方式: %s（可选 manual, scheduled, daemon）", trigger)
+	}
+}
+
+// updateTrigger 返回本次更新的触发方式
+func (app *App) updateTrigger() string {
+	switch {
+	case app.trigger != "":
+		return app.trigger
+	case app.unattended:
+		return triggerScheduled
+	default:
+		return triggerManual
+	}
+}
+
+// recordHistory 将一次更新追加到更新历史
+func (app *App) recordHistory(run *updateRun, err error) {
+	record := HistoryRecord{
+		ID:         run.id,
+		Time:       run.started.UTC(),
+		Trigger:    app.updateTrigger(),
+		Source:     run.source,
+		DurationMs: time.Since(run.started).Milliseconds(),
+		Result:     runOutcome(err),
+		Backup:     run.backup,
+		Entries:    run.entries,
+		Changes:    run.changes,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if record.Changes == nil {
+		record.Changes = []entryChange{}
+	}
+	if err := app.appendHistory(record); err != nil {
+		app.logWithLevel(WARNING, "写入更新历史失败: %v", err)
+	}
+}
+
+// appendHistory 以追加方式写入一条记录，每条记录占一行
+func (app *App) appendHistory(record HistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(app.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadHistory 读取全部更新历史，按时间从旧到新排列；无法解析的行跳过
+func (app *App) loadHistory() ([]HistoryRecord, error) {
+	f, err := os.Open(app.historyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []HistoryRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var record HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// queryHistory 返回满足条件的记录
+func (app *App) queryHistory(q historyQuery) ([]HistoryRecord, error) {
+	records, err := app.loadHistory()
+	if err != nil {
+		return nil, fmt.Errorf("读取更新历史失败: %w", err)
+	}
+	var matched []HistoryRecord
+	for _, r := range records {
+		if q.match(r) {
+			matched = append(matched, r)
+		}
+	}
+	if q.tail > 0 && len(matched) > q.tail {
+		matched = matched[len(matched)-q.tail:]
+	}
+	return matched, nil
+}
+
+// findHistoryRecord 按 ID 或 ID 前缀查找记录
+func (app *App) findHistoryRecord(id string) (*HistoryRecord, error) {
+	records, err := app.loadHistory()
+	if err != nil {
+		return nil, fmt.Errorf("读取更新历史失败: %w", err)
+	}
+	var found *HistoryRecord
+	for i := range records {
+		if !strings.HasPrefix(records[i].ID, id) {
+			continue
+		}
+		if found != nil && found.ID != records[i].ID {
+			return nil, fmt.Errorf("ID 前缀 %s 对应多条记录，请输入更长的 ID", id)
+		}
+		found = &records[i]
+	}
+	if found == nil {
+		return nil, fmt.Errorf("更新记录不存在: %s", id)
+	}
+	return found, nil
+}
+
+// historyStatus 返回最近一次尝试和最近一次成功的更新
+func (app *App) historyStatus() *HistoryStatus {
+	records, err := app.loadHistory()
+	if err != nil {
+		return &HistoryStatus{Error: err.Error()}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	status := &HistoryStatus{LastAttempt: &records[len(records)-1]}
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Result == runOutcomeSuccess {
+			status.LastSuccess = &records[i]
+			break
+		}
+	}
+	return status
+}
+
+// triggerText 返回触发方式的中文说明
+func triggerText(trigger string) string {
+	switch trigger {
+	case triggerManual:
+		return "手动"
+	case triggerScheduled:
+		return "定时任务"
+	case triggerDaemon:
+		return "守护进程"
+	default:
+		return trigger
+	}
+}
+
+// showHistory 以表格显示更新历史
+func (app *App) showHistory(q historyQuery) error {
+	records, err := app.queryHistory(q)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		app.logWithLevelOpt(INFO, false, "没有符合条件的更新记录")
+		return nil
+	}
+
+	fmt.Println("\n更新历史:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "时间\t触发\t结果\t耗时\t记录数\t变化\t数据源\tID")
+	for _, r := range records {
+		source := r.Source
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			r.Time.Local().Format("2006-01-02 15:04:05"),
+			triggerText(r.Trigger),
+			runOutcomeText(r.Result),
+			time.Duration(r.DurationMs)*time.Millisecond,
+			r.Entries,
+			len(r.Changes),
+			source,
+			r.ID)
+	}
+	w.Flush()
+	fmt.Println("\n使用 history --show <ID> 查看某次更新的详情和 IP 变化")
+	return nil
+}
+
+// showHistoryRecord 显示一次更新的详情
+func (app *App) showHistoryRecord(id string) error {
+	r, err := app.findHistoryRecord(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n更新 %s:\n", r.ID)
+	fmt.Printf("  时间: %s\n", r.Time.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("  触发: %s\n", triggerText(r.Trigger))
+	fmt.Printf("  结果: %s\n", runOutcomeText(r.Result))
+	fmt.Printf("  耗时: %s\n", time.Duration(r.DurationMs)*time.Millisecond)
+	if r.Source != "" {
+		fmt.Printf("  数据源: %s\n", r.Source)
+	}
+	if r.Backup != "" {
+		fmt.Printf("  更新前备份: %s\n", r.Backup)
+	}
+	if r.Error != "" {
+		fmt.Printf("  错误: %s\n", r.Error)
+	}
+	if r.Result == runOutcomeFailed && r.Source == "" {
+		return nil
+	}
+	fmt.Printf("\n=== 记录变化（写入 %d 条记录）===\n", r.Entries)
+	printEntryChanges(r.Changes)
+	return nil
+}
+
+// historyMenu 交互式菜单中的更新历史
+func (app *App) historyMenu() error {
+	for {
+		fmt.Println("\n[更新历史]")
+		fmt.Println("1. 最近的更新")
+		fmt.Println("2. 只看失败和回滚")
+		fmt.Println("3. 查看某次更新的详情")
+		fmt.Println("4. 查看某个域名的 IP 变化")
+		fmt.Println("0. 返回")
+		fmt.Print("请输入选项: ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+
+		q := historyQuery{tail: defaultHistoryTail}
+		var err error
+		switch choice {
+		case 1:
+			err = app.showHistory(q)
+		case 2:
+			err = app.showFailedHistory()
+		case 3:
+			fmt.Print("请输入更新 ID: ")
+			err = app.showHistoryRecord(readLine())
+		case 4:
+			fmt.Print("请输入域名: ")
+			q.domain = readLine()
+			err = app.showDomainHistory(q)
+		case 0:
+			return nil
+		default:
+			fmt.Println("无效的选项，请重试")
+		}
+		if err != nil {
+			app.logWithLevelOpt(ERROR, false, "%v", err)
+		}
+	}
+}
+
+// showFailedHistory 显示失败和回滚的更新
+func (app *App) showFailedHistory() error {
+	records, err := app.loadHistory()
+	if err != nil {
+		return fmt.Errorf("读取更新历史失败: %w", err)
+	}
+	var failed []HistoryRecord
+	for _, r := range records {
+		if r.Result != runOutcomeSuccess {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		app.logWithLevelOpt(INFO, false, "没有失败或回滚的更新")
+		return nil
+	}
+	if len(failed) > defaultHistoryTail {
+		failed = failed[len(failed)-defaultHistoryTail:]
+	}
+	for _, r := range failed {
+		fmt.Printf("%s  %s  %s  %s\n  %s\n",
+			r.Time.Local().Format("2006-01-02 15:04:05"), triggerText(r.Trigger), runOutcomeText(r.Result), r.ID, r.Error)
+	}
+	return nil
+}
+
+// showDomainHistory 显示某个域名在各次更新中的 IP 变化
+func (app *App) showDomainHistory(q historyQuery) error {
+	records, err := app.queryHistory(q)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		app.logWithLevelOpt(INFO, false, "%s 在更新历史中没有变化", q.domain)
+		return nil
+	}
+	fmt.Printf("\n%s 的 IP 变化:\n", q.domain)
+	for _, r := range records {
+		for _, c := range r.Changes {
+			if c.Domain != q.domain {
+				continue
+			}
+			from, to := c.OldIP, c.NewIP
+			if from == "" {
+				from = "(无)"
+			}
+			if to == "" {
+				to = "(无)"
+			}
+			fmt.Printf("%s  %s → %s  [%s, %s]\n",
+				r.Time.Local().Format("2006-01-02 15:04:05"), from, to, runOutcomeText(r.Result), r.ID)
+		}
+	}
+	return nil
+}
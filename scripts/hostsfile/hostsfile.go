@@ -0,0 +1,219 @@
+// Package hostsfile 解析 hosts 文件，将其拆分为用户自己的内容和本程序管理的 GitHub Hosts 区块。
+//
+// 所有修改都只作用于管理区块，区块之外的内容按字节原样保留。
+package hostsfile
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// 管理区块的开始和结束标记
+const (
+	StartMarker = "# ===== GitHub Hosts Start ====="
+	EndMarker   = "# ===== GitHub Hosts End ====="
+)
+
+var (
+	startMarkerPattern = regexp.MustCompile(`(?i)^#\s*=+\s*github\s+hosts\s+start\s*=+$`)
+	endMarkerPattern   = regexp.MustCompile(`(?i)^#\s*=+\s*github\s+hosts\s+end\s*=+$`)
+)
+
+// Entry 管理区块中的一条 "IP 域名" 记录
+type Entry struct {
+	IP     string
+	Domain string
+}
+
+// line 文件中的一行，raw 包含行尾换行符
+type line struct {
+	raw     []byte
+	managed bool
+}
+
+// File 解析后的 hosts 文件
+type File struct {
+	lines   []line
+	newline string
+}
+
+// IsStartMarker 判断一行是否为开始标记，忽略首尾空白、大小写和等号数量
+func IsStartMarker(s string) bool {
+	return startMarkerPattern.MatchString(strings.TrimSpace(s))
+}
+
+// IsEndMarker 判断一行是否为结束标记，忽略首尾空白、大小写和等号数量
+func IsEndMarker(s string) bool {
+	return endMarkerPattern.MatchString(strings.TrimSpace(s))
+}
+
+// Parse 解析 hosts 文件内容
+// 开始标记到其后第一个结束标记之间（含标记行）属于管理区块，文件中存在多个区块时全部视为管理区块。
+// 开始标记之后没有结束标记（在下一个开始标记或文件末尾之前）时，该标记行按用户内容处理，
+// 避免把其后的用户内容当作管理区块删除。
+func Parse(content []byte) *File {
+	f := &File{newline: "\n"}
+	if bytes.Contains(content, []byte("\r\n")) {
+		f.newline = "\r\n"
+	}
+
+	for len(content) > 0 {
+		raw := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			raw = content[:i+1]
+		}
+		content = content[len(raw):]
+		f.lines = append(f.lines, line{raw: raw})
+	}
+
+	for i := 0; i < len(f.lines); i++ {
+		if !IsStartMarker(string(f.lines[i].raw)) {
+			continue
+		}
+		end := f.blockEnd(i)
+		if end < 0 {
+			continue
+		}
+		for j := i; j <= end; j++ {
+			f.lines[j].managed = true
+		}
+		i = end
+	}
+	return f
+}
+
+// blockEnd 返回 start 行的开始标记对应的结束标记所在行
+// 在遇到下一个开始标记或文件末尾之前没有结束标记时返回 -1
+func (f *File) blockEnd(start int) int {
+	for j := start + 1; j < len(f.lines); j++ {
+		text := string(f.lines[j].raw)
+		switch {
+		case IsEndMarker(text):
+			return j
+		case IsStartMarker(text):
+			return -1
+		}
+	}
+	return -1
+}
+
+// HasBlock 文件中是否存在管理区块
+func (f *File) HasBlock() bool {
+	for _, l := range f.lines {
+		if l.managed {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockLines 返回管理区块内的行（不含标记行和行尾换行符）
+func (f *File) BlockLines() []string {
+	var lines []string
+	for _, l := range f.lines {
+		if !l.managed {
+			continue
+		}
+		text := strings.TrimRight(string(l.raw), "\r\n")
+		if IsStartMarker(text) || IsEndMarker(text) {
+			continue
+		}
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+// Entries 返回管理区块中的 hosts 记录，一行多个域名时拆分为多条
+func (f *File) Entries() []Entry {
+	var entries []Entry
+	for _, text := range f.BlockLines() {
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, domain := range fields[1:] {
+			entries = append(entries, Entry{IP: fields[0], Domain: domain})
+		}
+	}
+	return entries
+}
+
+// Block 返回管理区块的原始内容（含标记行和行尾换行符）
+func (f *File) Block() []byte {
+	var buf bytes.Buffer
+	for _, l := range f.lines {
+		if l.managed {
+			buf.Write(l.raw)
+		}
+	}
+	return buf.Bytes()
+}
+
+// UserContent 返回管理区块之外的内容
+func (f *File) UserContent() []byte {
+	var buf bytes.Buffer
+	for _, l := range f.lines {
+		if !l.managed {
+			buf.Write(l.raw)
+		}
+	}
+	return buf.Bytes()
+}
+
+// RemoveBlock 返回移除管理区块后的文件内容
+func (f *File) RemoveBlock() []byte {
+	return f.UserContent()
+}
+
+// ReplaceBlock 返回用 body 替换管理区块后的文件内容
+// 区块写在原区块所在位置；文件中没有区块时追加到末尾
+func (f *File) ReplaceBlock(body []string) []byte {
+	block := f.renderBlock(body)
+
+	var buf bytes.Buffer
+	written := false
+	for _, l := range f.lines {
+		if !l.managed {
+			buf.Write(l.raw)
+			continue
+		}
+		if !written {
+			// 前一行缺少换行符时补上，避免与标记行粘连
+			if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteString(f.newline)
+			}
+			buf.WriteString(block)
+			written = true
+		}
+	}
+
+	if !written {
+		if buf.Len() > 0 {
+			if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteString(f.newline)
+			}
+			// 与用户内容之间空一行
+			if last := f.lines[len(f.lines)-1]; strings.TrimSpace(string(last.raw)) != "" {
+				buf.WriteString(f.newline)
+			}
+		}
+		buf.WriteString(block)
+	}
+
+	return buf.Bytes()
+}
+
+// renderBlock 生成包含首尾标记的区块文本
+func (f *File) renderBlock(body []string) string {
+	var b strings.Builder
+	b.WriteString(StartMarker + f.newline)
+	for _, text := range body {
+		b.WriteString(strings.TrimRight(text, "\r\n") + f.newline)
+	}
+	b.WriteString(EndMarker + f.newline)
+	return b.String()
+}
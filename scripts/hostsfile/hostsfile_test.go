@@ -0,0 +1,152 @@
+package hostsfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// hasBlock 是否识别出管理区块
+		hasBlock bool
+		// user 管理区块之外的内容，RemoveBlock 应按字节原样返回
+		user string
+		// entries 管理区块中的记录
+		entries []Entry
+		// replaced 用 body 替换管理区块后的内容
+		replaced string
+	}{
+		{
+			name:     "没有区块",
+			content:  "127.0.0.1 localhost\n::1 localhost\n",
+			hasBlock: false,
+			user:     "127.0.0.1 localhost\n::1 localhost\n",
+			replaced: "127.0.0.1 localhost\n::1 localhost\n\n" +
+				StartMarker + "\n1.1.1.1 github.com\n" + EndMarker + "\n",
+		},
+		{
+			name: "区块在中间",
+			content: "127.0.0.1 localhost\n" +
+				StartMarker + "\n2.2.2.2 github.com\n" + EndMarker + "\n" +
+				"10.0.0.1 nas\n",
+			hasBlock: true,
+			user:     "127.0.0.1 localhost\n10.0.0.1 nas\n",
+			entries:  []Entry{{IP: "2.2.2.2", Domain: "github.com"}},
+			replaced: "127.0.0.1 localhost\n" +
+				StartMarker + "\n1.1.1.1 github.com\n" + EndMarker + "\n" +
+				"10.0.0.1 nas\n",
+		},
+		{
+			name: "CRLF 换行",
+			content: "127.0.0.1 localhost\r\n" +
+				StartMarker + "\r\n2.2.2.2 github.com api.github.com\r\n" + EndMarker + "\r\n",
+			hasBlock: true,
+			user:     "127.0.0.1 localhost\r\n",
+			entries: []Entry{
+				{IP: "2.2.2.2", Domain: "github.com"},
+				{IP: "2.2.2.2", Domain: "api.github.com"},
+			},
+			replaced: "127.0.0.1 localhost\r\n" +
+				StartMarker + "\r\n1.1.1.1 github.com\r\n" + EndMarker + "\r\n",
+		},
+		{
+			name: "缺少结束标记",
+			content: "127.0.0.1 localhost\n" +
+				StartMarker + "\n" +
+				"10.0.0.1 nas\n" +
+				"10.0.0.2 printer\n",
+			hasBlock: false,
+			user: "127.0.0.1 localhost\n" +
+				StartMarker + "\n" +
+				"10.0.0.1 nas\n" +
+				"10.0.0.2 printer\n",
+			replaced: "127.0.0.1 localhost\n" +
+				StartMarker + "\n" +
+				"10.0.0.1 nas\n" +
+				"10.0.0.2 printer\n\n" +
+				StartMarker + "\n1.1.1.1 github.com\n" + EndMarker + "\n",
+		},
+		{
+			name: "未结束的标记之后有完整区块",
+			content: StartMarker + "\n" +
+				"10.0.0.1 nas\n" +
+				StartMarker + "\n2.2.2.2 github.com\n" + EndMarker + "\n",
+			hasBlock: true,
+			user:     StartMarker + "\n10.0.0.1 nas\n",
+			entries:  []Entry{{IP: "2.2.2.2", Domain: "github.com"}},
+			replaced: StartMarker + "\n10.0.0.1 nas\n" +
+				StartMarker + "\n1.1.1.1 github.com\n" + EndMarker + "\n",
+		},
+		{
+			name: "重复区块",
+			content: "127.0.0.1 localhost\n" +
+				StartMarker + "\n2.2.2.2 github.com\n" + EndMarker + "\n" +
+				"10.0.0.1 nas\n" +
+				"# ==== github hosts START ====\n3.3.3.3 api.github.com\n# == GitHub Hosts End ==\n",
+			hasBlock: true,
+			user:     "127.0.0.1 localhost\n10.0.0.1 nas\n",
+			entries: []Entry{
+				{IP: "2.2.2.2", Domain: "github.com"},
+				{IP: "3.3.3.3", Domain: "api.github.com"},
+			},
+			replaced: "127.0.0.1 localhost\n" +
+				StartMarker + "\n1.1.1.1 github.com\n" + EndMarker + "\n" +
+				"10.0.0.1 nas\n",
+		},
+		{
+			name:     "最后一行没有换行符",
+			content:  "127.0.0.1 localhost",
+			hasBlock: false,
+			user:     "127.0.0.1 localhost",
+			replaced: "127.0.0.1 localhost\n\n" +
+				StartMarker + "\n1.1.1.1 github.com\n" + EndMarker + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Parse([]byte(tt.content))
+			if got := f.HasBlock(); got != tt.hasBlock {
+				t.Errorf("HasBlock() = %v, want %v", got, tt.hasBlock)
+			}
+			if got := string(f.RemoveBlock()); got != tt.user {
+				t.Errorf("RemoveBlock() = %q, want %q", got, tt.user)
+			}
+			if got := f.Entries(); !reflect.DeepEqual(got, tt.entries) {
+				t.Errorf("Entries() = %v, want %v", got, tt.entries)
+			}
+			if got := string(f.ReplaceBlock([]string{"1.1.1.1 github.com"})); got != tt.replaced {
+				t.Errorf("ReplaceBlock() = %q, want %q", got, tt.replaced)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	// 用原区块内容替换区块后，文件应与原来逐字节相同
+	tests := []string{
+		"",
+		"127.0.0.1 localhost\n",
+		"127.0.0.1 localhost\n" + StartMarker + "\n# (Source: x)\n2.2.2.2 github.com\n" + EndMarker + "\n10.0.0.1 nas\n",
+		"127.0.0.1 localhost\r\n" + StartMarker + "\r\n2.2.2.2 github.com\r\n" + EndMarker + "\r\n",
+		StartMarker + "\n" + EndMarker + "\n",
+	}
+
+	for _, content := range tests {
+		f := Parse([]byte(content))
+		if !f.HasBlock() {
+			if got := string(f.RemoveBlock()); got != content {
+				t.Errorf("RemoveBlock(%q) = %q", content, got)
+			}
+			continue
+		}
+		if got := string(f.ReplaceBlock(f.BlockLines())); got != content {
+			t.Errorf("ReplaceBlock(BlockLines()) of %q = %q", content, got)
+		}
+		if got := string(f.UserContent()) + string(f.Block()); len(got) != len(content) {
+			t.Errorf("UserContent()+Block() of %q has %d bytes, want %d", content, len(got), len(content))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// hostsLockTimeout 等待其他进程释放 hosts 锁的最长时间
+const hostsLockTimeout = 30 * time.Second
+
+// lockHosts 获取 hosts 文件的独占锁，防止定时任务与交互式操作同时修改 hosts
+// 返回的函数用于释放锁
+func (app *App) lockHosts() (func(), error) {
+	if err := os.MkdirAll(app.baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	path := filepath.Join(app.baseDir, "hosts.lock")
+	deadline := time.Now().Add(hostsLockTimeout)
+	for {
+		unlock, err := tryLockFile(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("获取 hosts 锁失败: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("另一个进程正在修改 hosts 文件，等待超时")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// errLocked 表示锁已被其他进程持有
+var errLocked = errors.New("锁已被占用")
+
+// writeHostsFile 以原子方式写入 hosts 文件
+// 先在同目录写入临时文件并同步到磁盘，保留原文件的权限和属主，再重命名覆盖
+func writeHostsFile(path string, content []byte) error {
+	return writeFileAtomic(path, content, 0)
+}
+
+// writeFileAtomic 以原子方式写入文件，perm 为 0 时保留原文件的权限（新文件为 0644）
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	info, statErr := os.Stat(path)
+	if perm == 0 {
+		perm = 0644
+		if statErr == nil {
+			perm = info.Mode().Perm()
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpPath := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpPath)
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		cleanup()
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return fmt.Errorf("同步临时文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("关闭临时文件失败: %w", err)
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("设置文件权限失败: %w", err)
+	}
+	if statErr == nil {
+		if err := copyOwner(tmpPath, info); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("设置文件属主失败: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		// 容器中通过 bind mount 挂载的 hosts 文件无法被替换，回退为原地写入
+		if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+			return writeFileInPlace(path, content, perm)
+		}
+		return fmt.Errorf("替换 hosts 文件失败: %w", err)
+	}
+
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// writeFileInPlace 截断并原地写入文件，仅在无法重命名替换时使用
+func writeFileInPlace(path string, content []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("打开 hosts 文件失败: %w", err)
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return fmt.Errorf("写入 hosts 文件失败: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("同步 hosts 文件失败: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleLockAge 超过该时长且持有进程已退出的锁文件视为异常退出遗留，可以被清理
+const staleLockAge = 10 * time.Minute
+
+// tryLockFile 通过独占创建锁文件获取锁
+func tryLockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		// 清理异常退出遗留的锁文件；守护进程在整个运行期间持有锁，持有进程仍在运行时不清理
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLockAge && !lockHolderAlive(path) {
+			os.Remove(path)
+		}
+		return nil, errLocked
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+
+	return func() {
+		os.Remove(path)
+	}, nil
+}
+
+// lockHolderAlive 判断锁文件中记录的进程是否仍在运行
+func lockHolderAlive(path string) bool {
+	var pid int
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if _, err := fmt.Sscan(string(data), &pid); err != nil {
+		return false
+	}
+	return processAlive(pid)
+}
+
+// copyOwner Windows 下文件属主由 ACL 继承，无需处理
+func copyOwner(path string, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir Windows 不支持同步目录
+func syncDir(dir string) {}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
 	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
 )
 
 func (app *App) installMenu() error {
@@ -16,7 +13,7 @@ func (app *App) installMenu() error {
 
 	// 首先检查是否已存在 hosts 数据
 	content, err := os.ReadFile(hostsFile)
-	if err == nil && strings.Contains(string(content), "GitHub Hosts") {
+	if err == nil && hostsfile.Parse(content).HasBlock() {
 		// 已存在 GitHub Hosts 数据，询问是否更新
 		fmt.Print("\n检测到已存在 GitHub Hosts 数据，是否要更新？[Y/n]: ")
 		var updateResponse string
@@ -40,41 +37,59 @@ func (app *App) installMenu() error {
 	app.logWithLevel(INFO, "开始安装配置向导...")
 
 	// 1. 选择是否开启自动更新
-	var autoUpdate bool = true // 默认开启
+	opts := installOptions{autoUpdate: true, schedule: intervalSchedule(defaultUpdateInterval)} // 默认开启，60 分钟
 	fmt.Print("\n是否开启自动更新？[Y/n]: ")
 	var response string
 	fmt.Scanf("%s", &response)
 
-	var interval int = 60 // 默认 60 分钟
 	if response == "n" || response == "N" {
-		autoUpdate = false
+		opts.autoUpdate = false
 		app.logWithLevel(INFO, "已禁用自动更新")
 	} else {
 		app.logWithLevel(INFO, "已启用自动更新")
 
-		fmt.Println("\n请选择更新间隔：")
-		fmt.Println("1. 每 30 分钟")
-		fmt.Println("2. 每 60 分钟")
-		fmt.Println("3. 每 120 分钟")
-		fmt.Print("请输入选项 (1-3): ")
-
-		var choice int
-		fmt.Scanf("%d", &choice)
-
-		switch choice {
-		case 1:
-			interval = 30
-		case 2:
-			interval = 60
-		case 3:
-			interval = 120
-		default:
-			app.logWithLevel(ERROR, "无效的选项，将使用默认间隔（60分钟）")
-			interval = 60
+		schedule, err := promptSchedule()
+		if err != nil {
+			app.logWithLevel(ERROR, "%v，将使用默认间隔（60分钟）", err)
+		} else {
+			opts.schedule = schedule
 		}
-		app.logWithLevel(INFO, "选择的更新间隔: %d 分钟", interval)
+		opts.schedule.Jitter = promptJitter(0)
+		app.logWithLevel(INFO, "选择的更新计划: %s", opts.schedule)
+	}
+
+	if err := app.install(opts); err != nil {
+		return err
+	}
+
+	// 显示当前 hosts 文件内容
+	app.logWithLevel(INFO, "\n当前 hosts 文件内容：")
+	fmt.Println("----------------------------------------")
+	content, err = os.ReadFile(hostsFile)
+	if err != nil {
+		app.logWithLevel(ERROR, "读取 hosts 文件失败: %v", err)
+	} else {
+		fmt.Println(string(content))
+	}
+	fmt.Println("----------------------------------------")
+
+	// 自动执行网络连接测试
+	app.logWithLevel(INFO, "\n开始测试网络连接...")
+	if err := app.testConnection(); err != nil {
+		app.logWithLevel(WARNING, "网络连接测试出现问题: %v", err)
 	}
 
+	return nil
+}
+
+// installOptions 安装参数，对应安装向导中的各项选择
+type installOptions struct {
+	autoUpdate bool
+	schedule   ScheduleConfig
+}
+
+// install 按给定参数执行完整的安装流程
+func (app *App) install(opts installOptions) error {
 	app.logWithLevel(INFO, "开始执行安装流程...")
 
 	// 1. Setup directories
@@ -91,7 +106,7 @@ func (app *App) installMenu() error {
 
 	// 2. Update config
 	app.logWithLevel(INFO, "第 2/4 步: 更新配置文件")
-	if err := app.updateConfig(interval, autoUpdate); err != nil {
+	if err := app.updateConfig(opts.schedule, opts.autoUpdate); err != nil {
 		app.logWithLevel(ERROR, "更新配置失败: %v", err)
 		return fmt.Errorf("更新配置失败: %w", err)
 	}
@@ -106,9 +121,9 @@ func (app *App) installMenu() error {
 	app.logWithLevel(SUCCESS, "hosts 文件更新完成")
 
 	// 4. Setup cron
-	if autoUpdate {
+	if opts.autoUpdate {
 		app.logWithLevel(INFO, "第 4/4 步: 设置定时更新任务")
-		if err := app.setupCron(interval); err != nil {
+		if err := app.setupCron(opts.schedule); err != nil {
 			app.logWithLevel(ERROR, "设置定时任务失败: %v", err)
 			return fmt.Errorf("设置定时任务失败: %w", err)
 		}
@@ -120,31 +135,14 @@ func (app *App) installMenu() error {
 	// 显示安装完成信息
 	app.logWithLevel(SUCCESS, "安装完成！")
 	app.logWithLevel(INFO, "系统配置信息：")
-	if autoUpdate {
-		app.logWithLevel(INFO, "  • 更新间隔: 每 %d 分钟", interval)
+	if opts.autoUpdate {
+		app.logWithLevel(INFO, "  • 更新计划: %s", opts.schedule)
 	}
-	app.logWithLevel(INFO, "  • 自动更新: %s", map[bool]string{true: "已启用", false: "已禁用"}[autoUpdate])
+	app.logWithLevel(INFO, "  • 自动更新: %s", map[bool]string{true: "已启用", false: "已禁用"}[opts.autoUpdate])
 	app.logWithLevel(INFO, "  • 配置文件: %s", app.configFile)
-	app.logWithLevel(INFO, "  • 日志文件: %s", filepath.Join(app.logDir, "update.log"))
+	app.logWithLevel(INFO, "  • 日志文件: %s", app.logPath())
 	app.logWithLevel(INFO, "  • 备份目录: %s", app.backupDir)
 
-	// 显示当前 hosts 文件内容
-	app.logWithLevel(INFO, "\n当前 hosts 文件内容：")
-	fmt.Println("----------------------------------------")
-	content, err = os.ReadFile(hostsFile)
-	if err != nil {
-		app.logWithLevel(ERROR, "读取 hosts 文件失败: %v", err)
-	} else {
-		fmt.Println(string(content))
-	}
-	fmt.Println("----------------------------------------")
-
-	// 自动执行网络连接测试
-	app.logWithLevel(INFO, "\n开始测试网络连接...")
-	if err := app.testConnection(); err != nil {
-		app.logWithLevel(WARNING, "网络连接测试出现问题: %v", err)
-	}
-
 	return nil
 }
 
@@ -158,78 +156,116 @@ func (app *App) setupDirectories() error {
 	return nil
 }
 
-func (app *App) updateConfig(interval int, autoUpdate bool) error {
-	config := Config{
-		UpdateInterval: interval,
-		LastUpdate:     time.Now().UTC(),
-		Version:        "1.0.0",
-		AutoUpdate:     autoUpdate,
-	}
-
-	data, err := json.MarshalIndent(config, "", "    ")
-	if err != nil {
-		return err
-	}
+// updateConfig 修改更新计划和自动更新开关，其他配置项保持不变
+func (app *App) updateConfig(schedule ScheduleConfig, autoUpdate bool) error {
+	return app.modifyConfig(func(config *Config) error {
+		// updateInterval 供旧版本读取，非固定间隔的计划记为 0
+		config.Schedule = &schedule
+		config.UpdateInterval = 0
+		if schedule.Kind == scheduleInterval {
+			config.UpdateInterval = schedule.Interval
+		}
+		config.Version = appVersion
+		config.AutoUpdate = autoUpdate
+		return nil
+	})
+}
 
-	return os.WriteFile(app.configFile, data, 0644)
+// fetchedUpdate 获取到的新 hosts 数据
+type fetchedUpdate struct {
+	body   []string     // 管理区块的内容
+	source string       // 数据源名称
+	probe  *ProbeReport // 候选 IP 探测结果，未探测时为 nil；只在 hosts 写入成功后保存
 }
 
-func (app *App) updateHosts() error {
-	app.logWithLevel(INFO, "开始备份当前 hosts 文件")
-	if err := app.backupHosts(); err != nil {
-		return fmt.Errorf("backup failed: %w", err)
+// fetchUpdate 获取并校验新的 hosts 数据，不写入任何文件
+// 包括请求数据源、DoH 解析和候选 IP 探测，可能耗时较长，调用时不应持有 hosts 锁
+func (app *App) fetchUpdate(config *Config) (*fetchedUpdate, error) {
+	// 依次尝试各数据源，并校验数据，避免把强制门户页面或不完整的响应写入 hosts
+	entries, source, err := app.hostsEntries(config)
+	if err != nil {
+		return nil, err
 	}
-	app.logWithLevel(SUCCESS, "hosts 文件备份完成")
+	app.logWithLevel(SUCCESS, "已从 %s 获取 hosts 数据，校验通过，共 %d 条记录", source, len(entries))
+
+	// 同一域名有多个候选 IP 时，按探测结果只保留最优的一个
+	entries, probe := app.selectBestEntries(config, entries)
+	return &fetchedUpdate{
+		body:   renderHostsBlock(entries, source, time.Now()),
+		source: source,
+		probe:  probe,
+	}, nil
+}
 
-	// 先清理已存在的 GitHub Hosts 内容
-	app.logWithLevel(INFO, "清理已存在的 GitHub Hosts 内容")
-	if err := app.cleanHostsFile(); err != nil {
-		return fmt.Errorf("清理已存在内容失败: %w", err)
+// prepareUpdate 获取新的 hosts 数据，并在内存中生成更新后的完整 hosts 内容，不写入任何文件
+// 供 update --dry-run 和 diff 预览使用，返回新内容和数据源名称
+func (app *App) prepareUpdate(config *Config) ([]byte, string, error) {
+	update, err := app.fetchUpdate(config)
+	if err != nil {
+		return nil, "", err
 	}
-	app.logWithLevel(SUCCESS, "已清理旧的 hosts 内容")
 
-	app.logWithLevel(INFO, "正在从服务器获取最新 hosts 数据")
-	resp, err := http.Get(hostsAPI)
+	// 只替换管理区块，其他内容保持不变
+	current, err := os.ReadFile(hostsFile)
 	if err != nil {
-		return fmt.Errorf("failed to download hosts: %w", err)
+		return nil, "", fmt.Errorf("failed to read hosts file: %w", err)
 	}
-	defer resp.Body.Close()
+	return hostsfile.Parse(current).ReplaceBlock(update.body), update.source, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status code: %d", resp.StatusCode)
-	}
+func (app *App) updateHosts() error {
+	run := app.beginRun()
+	err := app.applyUpdate(run)
+	app.endRun(run, err)
+	app.recordHistory(run, err)
+	return err
+}
 
-	content, err := io.ReadAll(resp.Body)
+// applyUpdate 执行一次更新，并在 run 中记录使用的数据源和写入的记录数
+func (app *App) applyUpdate(run *updateRun) error {
+	// 网络请求、解析和探测可能耗时较长，在获取锁之前完成，避免其他进程等待 hosts 锁超时
+	config, err := app.loadConfigOrDefault()
 	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+		app.logWithLevel(ERROR, "%v，已放弃更新，现有记录保持不变", err)
+		return err
 	}
-	app.logWithLevel(SUCCESS, "成功获取最新 hosts 数据")
-
-	app.logWithLevel(INFO, "正在更新本地 hosts 文件")
-	f, err := os.OpenFile(hostsFile, os.O_APPEND|os.O_WRONLY, 0644)
+	update, err := app.fetchUpdate(config)
 	if err != nil {
-		return fmt.Errorf("failed to open hosts file: %w", err)
+		app.logWithLevel(ERROR, "获取 hosts 数据失败，已放弃更新，现有记录保持不变: %v", err)
+		return err
 	}
-	defer f.Close()
+	source := update.source
+	run.source = source
 
-	// 添加开始标记和更新时间
-	startMarker := fmt.Sprintf("\n# ===== GitHub Hosts Start ===== \n# (Updated: %s)\n",
-		time.Now().Format("2006-01-02 15:04:05"))
-	if _, err := f.WriteString(startMarker); err != nil {
-		return fmt.Errorf("failed to write start marker: %w", err)
+	// 获取锁，避免与其他更新进程交叉写入；锁内重新读取 hosts，保留等待期间其他进程的修改
+	unlock, err := app.lockHosts()
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	// 写入 hosts 内容
-	if _, err := f.Write(content); err != nil {
-		return fmt.Errorf("failed to write hosts content: %w", err)
+	current, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return fmt.Errorf("failed to read hosts file: %w", err)
 	}
+	currentFile := hostsfile.Parse(current)
+	newContent := currentFile.ReplaceBlock(update.body)
+	newEntries := hostsfile.Parse(newContent).Entries()
+	run.entries = len(newEntries)
+	run.changes = diffEntries(currentFile.Entries(), newEntries)
 
-	// 添加结束标记
-	endMarker := "# ===== GitHub Hosts End =====\n"
-	if _, err := f.WriteString(endMarker); err != nil {
-		return fmt.Errorf("failed to write end marker: %w", err)
+	app.logWithLevel(INFO, "开始备份当前 hosts 文件")
+	backupName, err := app.backupHosts(backupReasonPreUpdate, source)
+	if err != nil {
+		return fmt.Errorf("backup failed: %w", err)
 	}
+	run.backup = backupName
+	app.logWithLevel(SUCCESS, "hosts 文件备份完成")
 
+	app.logWithLevel(INFO, "正在更新本地 hosts 文件")
+	if err := writeHostsFile(hostsFile, newContent); err != nil {
+		return err
+	}
 	app.logWithLevel(SUCCESS, "hosts 文件更新成功")
 
 	app.logWithLevel(INFO, "正在刷新 DNS 缓存")
@@ -239,5 +275,17 @@ func (app *App) updateHosts() error {
 		app.logWithLevel(SUCCESS, "DNS 缓存刷新完成")
 	}
 
+	// 启用健康检查时，新记录不可用会自动回滚
+	if err := app.guardUpdate(config, newContent, backupName, source); err != nil {
+		return err
+	}
+	app.recordSource(source)
+	// 新记录已生效，status 显示的探测结果与 hosts 中的选择保持一致
+	if update.probe != nil {
+		if err := app.saveProbeReport(update.probe); err != nil {
+			app.logWithLevel(WARNING, "保存探测结果失败: %v", err)
+		}
+	}
+
 	return nil
 }
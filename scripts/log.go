@@ -1,12 +1,319 @@
 package main
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// 日志文件格式
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// 默认的日志配置
+const (
+	defaultLogFormat     = logFormatText
+	defaultLogLevel      = "info"
+	defaultLogMaxSizeMB  = 10
+	defaultLogMaxAgeDays = 30
+	defaultLogMaxBackups = 10
+)
+
+// logFileName 当前日志文件名，交互、定时任务和守护进程共用
+const logFileName = "github-hosts.log"
+
+// slogLevelSuccess SUCCESS 在 slog 中对应的级别，介于 INFO 和 WARN 之间
+const slogLevelSuccess = slog.LevelInfo + 2
+
+// LogConfig 日志配置
+// 日志文件超过 MaxSizeMB 时轮转，轮转后的文件按 MaxAgeDays 和 MaxBackups 清理
+type LogConfig struct {
+	Format     string `json:"format,omitempty"`     // text 或 json，未配置时使用 text
+	Level      string `json:"level,omitempty"`      // 写入文件的最低级别：debug, info, warn, error
+	MaxSizeMB  int    `json:"maxSizeMB,omitempty"`  // 单个日志文件的大小上限（MB），0 表示使用默认值
+	MaxAgeDays int    `json:"maxAgeDays,omitempty"` // 轮转后的日志保留天数，0 表示使用默认值
+	MaxBackups int    `json:"maxBackups,omitempty"` // 轮转后的日志最多保留的个数，0 表示使用默认值
+	Compress   *bool  `json:"compress,omitempty"`   // 是否压缩轮转后的日志，未配置时压缩
+}
+
+// logging 返回日志配置，未配置的项使用默认值
+func (c *Config) logging() LogConfig {
+	var l LogConfig
+	if c != nil && c.Log != nil {
+		l = *c.Log
+	}
+	if l.Format == "" {
+		l.Format = defaultLogFormat
+	}
+	if l.Level == "" {
+		l.Level = defaultLogLevel
+	}
+	if l.MaxSizeMB <= 0 {
+		l.MaxSizeMB = defaultLogMaxSizeMB
+	}
+	if l.MaxAgeDays <= 0 {
+		l.MaxAgeDays = defaultLogMaxAgeDays
+	}
+	if l.MaxBackups <= 0 {
+		l.MaxBackups = defaultLogMaxBackups
+	}
+	if l.Compress == nil {
+		compress := true
+		l.Compress = &compress
+	}
+	return l
+}
+
+// parseLogLevel 将配置中的级别名称转换为 slog 级别
+func parseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "success":
+		return slogLevelSuccess, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("无效的日志级别: %s（可选 debug, info, success, warn, error）", name)
+	}
+}
+
+// slogLevel 返回日志级别对应的 slog 级别
+func (level LogLevel) slogLevel() slog.Level {
+	switch level {
+	case DEBUG:
+		return slog.LevelDebug
+	case SUCCESS:
+		return slogLevelSuccess
+	case WARNING:
+		return slog.LevelWarn
+	case ERROR:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// replaceLogLevel 让 SUCCESS 级别在日志文件中显示为 SUCCESS 而不是 INFO+2
+func replaceLogLevel(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == slogLevelSuccess {
+			a.Value = slog.StringValue("SUCCESS")
+		}
+	}
+	return a
+}
+
+// setLogging 修改日志配置中的一项，key 为 format、level、maxSizeMB、maxAgeDays、maxBackups 或 compress
+func (app *App) setLogging(key, value string) error {
+	err := app.modifyConfig(func(config *Config) error {
+		var settings LogConfig
+		if config.Log != nil {
+			settings = *config.Log
+		}
+		switch key {
+		case "format":
+			if value != logFormatText && value != logFormatJSON {
+				return fmt.Errorf("无效的日志格式: %s（可选 text, json）", value)
+			}
+			settings.Format = value
+		case "level":
+			if _, err := parseLogLevel(value); err != nil {
+				return err
+			}
+			settings.Level = strings.ToLower(value)
+		case "maxSizeMB", "maxAgeDays", "maxBackups":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("log.%s 必须是正整数", key)
+			}
+			switch key {
+			case "maxSizeMB":
+				settings.MaxSizeMB = n
+			case "maxAgeDays":
+				settings.MaxAgeDays = n
+			default:
+				settings.MaxBackups = n
+			}
+		case "compress":
+			compress, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("log.compress 必须是 true 或 false")
+			}
+			settings.Compress = &compress
+		default:
+			return fmt.Errorf("未知的日志配置项: %s", key)
+		}
+		config.Log = &settings
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 按新配置重新打开日志文件
+	app.closeLog()
+	app.logWithLevel(SUCCESS, "日志配置 %s 已修改为 %s", key, value)
+	return nil
+}
+
+// 日志文件中的结构化字段
+const (
+	logKeyRun        = "run"        // 更新的 ID
+	logKeyEvent      = "event"      // 运行开始或结束
+	logKeyOutcome    = "outcome"    // 更新结果
+	logKeySource     = "source"     // 使用的数据源
+	logKeyEntries    = "entries"    // 写入的记录数
+	logKeyDurationMs = "durationMs" // 更新耗时（毫秒）
+	logKeyError      = "error"      // 失败原因
+)
+
+// 运行事件
+const (
+	runEventStart = "run_start"
+	runEventEnd   = "run_end"
+)
+
+// 更新结果
+const (
+	runOutcomeSuccess    = "success"
+	runOutcomeFailed     = "failed"
+	runOutcomeRolledBack = "rolled_back"
+)
+
+// updateRun 一次更新的信息，结束时写入运行摘要
+type updateRun struct {
+	id      string
+	started time.Time
+	source  string
+	entries int
+	backup  string        // 更新前的备份文件名
+	changes []entryChange // 按域名的 IP 变化
+}
+
+// runOutcome 根据更新返回的错误判断更新结果
+func runOutcome(err error) string {
+	switch {
+	case err == nil:
+		return runOutcomeSuccess
+	case errors.Is(err, errRolledBack):
+		return runOutcomeRolledBack
+	default:
+		return runOutcomeFailed
+	}
+}
+
+// newRunID 生成运行 ID
+func newRunID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// beginRun 开始一次更新，之后的日志都附带该次更新的 ID
+func (app *App) beginRun() *updateRun {
+	run := &updateRun{id: newRunID(), started: time.Now()}
+	app.runID = run.id
+	// 开始和失败的摘要只写入日志文件，控制台已有对应的输出
+	app.writeLogRecord(INFO, run.started, "开始更新 hosts 文件", logKeyEvent, runEventStart)
+	return run
+}
+
+// endRun 写入本次更新的摘要：数据源、写入的记录数、耗时和结果
+func (app *App) endRun(run *updateRun, err error) {
+	defer func() { app.runID = "" }()
+
+	duration := time.Since(run.started)
+	attrs := []interface{}{
+		logKeyEvent, runEventEnd,
+		logKeySource, run.source,
+		logKeyEntries, run.entries,
+		logKeyDurationMs, duration.Milliseconds(),
+	}
+	if err == nil {
+		app.logEvent(INFO, fmt.Sprintf("本次更新完成: 写入 %d 条记录，耗时 %s", run.entries, duration.Round(time.Millisecond)),
+			append(attrs, logKeyOutcome, runOutcomeSuccess)...)
+		return
+	}
+	app.writeLogRecord(ERROR, time.Now(), fmt.Sprintf("本次更新失败，耗时 %s", duration.Round(time.Millisecond)),
+		append(attrs, logKeyOutcome, runOutcome(err), logKeyError, err.Error())...)
+}
+
+// logPath 返回当前日志文件路径
+func (app *App) logPath() string {
+	return filepath.Join(app.logDir, logFileName)
+}
+
+// fileLogger 返回写入日志文件的 logger，首次调用时按配置创建
+func (app *App) fileLogger() *slog.Logger {
+	app.logMu.Lock()
+	defer app.logMu.Unlock()
+	if app.logger != nil {
+		return app.logger
+	}
+
+	// 日志锁已持有，只能输出到控制台
+	config, err := app.loadConfigOrDefault()
+	if err != nil {
+		app.logWithLevelOpt(WARNING, false, "%v，日志使用默认设置", err)
+	}
+	settings := config.logging()
+	level, err := parseLogLevel(settings.Level)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+
+	app.logWriter = &rotatingFile{
+		path:       app.logPath(),
+		maxSize:    int64(settings.MaxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(settings.MaxAgeDays) * 24 * time.Hour,
+		maxBackups: settings.MaxBackups,
+		compress:   *settings.Compress,
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceLogLevel}
+	var handler slog.Handler
+	if settings.Format == logFormatJSON {
+		handler = slog.NewJSONHandler(app.logWriter, opts)
+	} else {
+		handler = slog.NewTextHandler(app.logWriter, opts)
+	}
+	app.logger = slog.New(handler).With("pid", os.Getpid())
+
+	// 清理过期的日志，包括旧版本按天生成的 update_YYYYMMDD.log
+	app.logWriter.prune()
+	return app.logger
+}
+
+// closeLog 关闭日志文件，下次写入时按最新配置重新打开
+func (app *App) closeLog() {
+	app.logMu.Lock()
+	defer app.logMu.Unlock()
+	if app.logWriter != nil {
+		app.logWriter.Close()
+	}
+	app.logger = nil
+	app.logWriter = nil
+}
+
 // logWithLevel 输出带有级别的日志，并同时写入日志文件
 // writeToFile 参数控制是否写入日志文件，默认为 true
 func (app *App) logWithLevel(level LogLevel, format string, args ...interface{}) {
@@ -14,6 +321,7 @@ func (app *App) logWithLevel(level LogLevel, format string, args ...interface{})
 }
 
 // logWithLevelOpt 输出带有级别的日志，可选择是否写入日志文件
+// 控制台保留图标前缀，日志文件由 slog 按配置的格式写入，DEBUG 级别只写入文件
 func (app *App) logWithLevelOpt(level LogLevel, writeToFile bool, format string, args ...interface{}) {
 	var prefix string
 	switch level {
@@ -27,37 +335,218 @@ func (app *App) logWithLevelOpt(level LogLevel, writeToFile bool, format string,
 		prefix = "❌ "
 	}
 
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	now := time.Now()
 	message := fmt.Sprintf(format, args...)
-	logLine := fmt.Sprintf("%s[%s] %s\n", prefix, timestamp, message)
 
 	// 输出到控制台
-	fmt.Print(logLine)
+	if level != DEBUG {
+		fmt.Printf("%s[%s] %s\n", prefix, now.Format("2006-01-02 15:04:05"), message)
+	}
 
 	// 如果不需要写入文件，直接返回
 	if !writeToFile {
 		return
 	}
+	app.writeLogRecord(level, now, message)
+}
 
-	// 写入到日志文件
-	logFile := filepath.Join(app.logDir, fmt.Sprintf("update_%s.log", time.Now().Format("20060102")))
+// logEvent 输出日志并在日志文件中附带结构化字段，attrs 为交替的键和值
+func (app *App) logEvent(level LogLevel, message string, attrs ...interface{}) {
+	now := time.Now()
+	if level != DEBUG {
+		app.logWithLevelOpt(level, false, "%s", message)
+	}
+	app.writeLogRecord(level, now, message, attrs...)
+}
 
-	// 确保日志目录存在
-	if err := os.MkdirAll(app.logDir, 0755); err != nil {
-		fmt.Printf("❌ 创建日志目录失败: %v\n", err)
+// writeLogRecord 将一条日志写入日志文件，更新过程中的日志附带本次运行的 ID
+func (app *App) writeLogRecord(level LogLevel, now time.Time, message string, attrs ...interface{}) {
+	handler := app.fileLogger().Handler()
+	ctx := context.Background()
+	if !handler.Enabled(ctx, level.slogLevel()) {
 		return
 	}
+	record := slog.NewRecord(now, level.slogLevel(), message, 0)
+	if app.runID != "" {
+		record.Add(logKeyRun, app.runID)
+	}
+	record.Add(attrs...)
+	if err := handler.Handle(ctx, record); err != nil {
+		fmt.Printf("❌ 写入日志失败: %v\n", err)
+	}
+}
+
+// rotatingFile 按大小轮转的日志文件
+// 多个进程可能同时写入同一个文件，每次写入前检查文件是否已被其他进程轮转
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	file       *os.File
+}
+
+// Write 写入一条日志，写入后超过大小上限时先轮转
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	// 以追加模式打开日志文件
-	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	size, err := w.open()
+	if err != nil {
+		return 0, err
+	}
+	if size > 0 && size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+		if _, err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	return w.file.Write(p)
+}
+
+// open 确保日志文件已打开且仍是 path 指向的文件，返回当前文件大小
+func (w *rotatingFile) open() (int64, error) {
+	if w.file != nil {
+		current, err := os.Stat(w.path)
+		opened, ferr := w.file.Stat()
+		if err == nil && ferr == nil && os.SameFile(current, opened) {
+			return current.Size(), nil
+		}
+		// 文件已被其他进程轮转或删除，重新打开
+		w.file.Close()
+		w.file = nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return 0, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	w.file = f
+	return info.Size(), nil
+}
+
+// rotate 将当前日志文件重命名为带时间戳的文件，按配置压缩并清理过期的日志
+func (w *rotatingFile) rotate() error {
+	w.file.Close()
+	w.file = nil
+
+	ext := filepath.Ext(w.path)
+	rotated := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(w.path, ext), time.Now().Format("20060102-150405.000"), ext)
+	if err := os.Rename(w.path, rotated); err != nil {
+		if os.IsNotExist(err) {
+			// 其他进程已经完成轮转
+			return nil
+		}
+		return fmt.Errorf("轮转日志文件失败: %w", err)
+	}
+	if w.compress {
+		if err := gzipFile(rotated); err != nil {
+			fmt.Printf("⚠️  压缩日志文件失败: %v\n", err)
+		}
+	}
+	w.prune()
+	return nil
+}
+
+// prune 删除超过保留天数或个数的轮转日志，以及旧版本生成的日志
+func (w *rotatingFile) prune() {
+	dir := filepath.Dir(w.path)
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		fmt.Printf("❌ 打开日志文件失败: %v\n", err)
 		return
 	}
-	defer f.Close()
 
-	// 写入日志
-	if _, err := f.WriteString(logLine); err != nil {
-		fmt.Printf("❌ 写入日志失败: %v\n", err)
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var rotated []logFile
+	cutoff := time.Now().Add(-w.maxAge)
+	for _, entry := range entries {
+		if entry.IsDir() || !isRotatedLog(filepath.Base(w.path), entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if info.ModTime().Before(cutoff) {
+			os.Remove(path)
+			continue
+		}
+		rotated = append(rotated, logFile{path: path, modTime: info.ModTime()})
+	}
+
+	sort.Slice(rotated, func(i, j int) bool { return rotated[i].modTime.After(rotated[j].modTime) })
+	for i := w.maxBackups; i < len(rotated); i++ {
+		os.Remove(rotated[i].path)
+	}
+}
+
+// isRotatedLog 判断文件是否为轮转后的日志或旧版本生成的日志（update.log、update_YYYYMMDD.log）
+func isRotatedLog(current, name string) bool {
+	if name == "update.log" || (strings.HasPrefix(name, "update_") && strings.HasSuffix(name, ".log")) {
+		return true
+	}
+	ext := filepath.Ext(current)
+	prefix := strings.TrimSuffix(current, ext) + "-"
+	return strings.HasPrefix(name, prefix) &&
+		(strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz"))
+}
+
+// Close 关闭日志文件
+func (w *rotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// gzipFile 将文件压缩为 .gz 并删除原文件
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
 	}
+	src.Close()
+	return os.Remove(path)
 }
@@ -0,0 +1,633 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 日志查看的默认条数
+const (
+	defaultLogTail  = 50
+	defaultRunsTail = 20
+)
+
+// logFollowInterval 跟踪日志时检查新内容的间隔
+const logFollowInterval = 500 * time.Millisecond
+
+// LogEntry 日志文件中的一条日志
+type LogEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Message string            `json:"msg"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// RunSummary 一次更新的摘要，由同一运行 ID 的日志汇总得到
+type RunSummary struct {
+	ID         string    `json:"id"`
+	PID        string    `json:"pid,omitempty"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished,omitempty"`
+	Source     string    `json:"source,omitempty"`
+	Entries    int       `json:"entries"`
+	DurationMs int64     `json:"durationMs"`
+	Outcome    string    `json:"outcome"` // success, failed, rolled_back；没有结束记录时为 unfinished
+	Error      string    `json:"error,omitempty"`
+	Warnings   int       `json:"warnings"`
+}
+
+// runOutcomeUnfinished 没有结束记录的运行：正在进行或进程异常退出
+const runOutcomeUnfinished = "unfinished"
+
+// LogsReport logs --output json 的输出
+type LogsReport struct {
+	reportHeader
+	Entries []LogEntry `json:"entries"`
+}
+
+// RunsReport logs --runs --output json 的输出
+type RunsReport struct {
+	reportHeader
+	Runs []RunSummary `json:"runs"`
+}
+
+// logQuery 日志查看条件
+type logQuery struct {
+	minLevel slog.Level
+	since    time.Time // 为零时不限制
+	until    time.Time // 为零时不限制
+	keyword  string    // 不区分大小写
+	run      string    // 只看指定运行 ID 的日志
+	tail     int       // 只显示最后 N 条，0 表示全部
+}
+
+// newLogQuery 返回显示最近日志的默认条件
+func newLogQuery() logQuery {
+	return logQuery{minLevel: slog.LevelDebug, tail: defaultLogTail}
+}
+
+// match 判断日志是否满足条件
+func (q logQuery) match(e LogEntry) bool {
+	if entryLevel(e) < q.minLevel {
+		return false
+	}
+	if !q.since.IsZero() && e.Time.Before(q.since) {
+		return false
+	}
+	if !q.until.IsZero() && !e.Time.Before(q.until) {
+		return false
+	}
+	if q.run != "" && e.Attrs[logKeyRun] != q.run {
+		return false
+	}
+	if q.keyword != "" && !strings.Contains(strings.ToLower(e.Message), strings.ToLower(q.keyword)) {
+		return false
+	}
+	return true
+}
+
+// entryLevel 返回日志的 slog 级别，无法识别时按 INFO 处理
+func entryLevel(e LogEntry) slog.Level {
+	level, err := parseLogLevel(e.Level)
+	if err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// parseLogTime 解析日期条件：YYYY-MM-DD、YYYY-MM-DD HH:MM 或相对时间（30m、24h、7d）
+// endOfDay 为 true 时，只有日期的值表示当天结束（用于 --until）
+func parseLogTime(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s（可用 2006-01-02、\"2006-01-02 15:04\" 或 30m、24h、7d）", value)
+}
+
+// logFiles 返回所有日志文件，按修改时间从旧到新排列，当前日志文件排在最后
+func (app *App) logFiles() ([]string, error) {
+	entries, err := os.ReadDir(app.logDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	for _, entry := range entries {
+		if entry.IsDir() || !isRotatedLog(logFileName, entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{path: filepath.Join(app.logDir, entry.Name()), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	paths := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	if _, err := os.Stat(app.logPath()); err == nil {
+		paths = append(paths, app.logPath())
+	}
+	return paths, nil
+}
+
+// readLogEntries 读取所有日志文件中的日志，按时间排序
+func (app *App) readLogEntries() ([]LogEntry, error) {
+	paths, err := app.logFiles()
+	if err != nil {
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+
+	var entries []LogEntry
+	for _, path := range paths {
+		fileEntries, err := readLogFile(path)
+		if err != nil {
+			app.logWithLevelOpt(WARNING, false, "读取日志文件 %s 失败: %v", filepath.Base(path), err)
+			continue
+		}
+		entries = append(entries, fileEntries...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// readLogFile 读取单个日志文件，支持 gzip 压缩的轮转日志
+func readLogFile(path string) ([]LogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var entries []LogEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if entry, ok := parseLogLine(scanner.Text()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// legacyLogLine 旧版本日志的格式：图标 [2006-01-02 15:04:05] 消息
+var legacyLogLine = regexp.MustCompile(`^(\S*)\s*\[(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\] (.*)$`)
+
+// parseLogLine 解析一行日志，支持 JSON、slog 文本格式和旧版本的格式
+func parseLogLine(line string) (LogEntry, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return LogEntry{}, false
+	}
+
+	var fields map[string]string
+	if strings.HasPrefix(line, "{") {
+		fields = parseJSONLogFields(line)
+	} else if strings.HasPrefix(line, slog.TimeKey+"=") {
+		fields = parseTextLogFields(line)
+	} else if m := legacyLogLine.FindStringSubmatch(line); m != nil {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", m[2], time.Local)
+		if err != nil {
+			return LogEntry{}, false
+		}
+		return LogEntry{Time: t, Level: legacyLogLevel(m[1]), Message: m[3]}, true
+	}
+	if fields == nil {
+		return LogEntry{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, fields[slog.TimeKey])
+	if err != nil {
+		return LogEntry{}, false
+	}
+	entry := LogEntry{Time: t, Level: fields[slog.LevelKey], Message: fields[slog.MessageKey]}
+	delete(fields, slog.TimeKey)
+	delete(fields, slog.LevelKey)
+	delete(fields, slog.MessageKey)
+	if len(fields) > 0 {
+		entry.Attrs = fields
+	}
+	return entry, true
+}
+
+// parseJSONLogFields 解析 JSON 格式的一行日志，所有值转换为字符串
+func parseJSONLogFields(line string) map[string]string {
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var raw map[string]interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil
+	}
+	fields := make(map[string]string, len(raw))
+	for key, value := range raw {
+		fields[key] = fmt.Sprint(value)
+	}
+	return fields
+}
+
+// parseTextLogFields 解析 slog 文本格式的一行日志（key=value，值可能带引号）
+func parseTextLogFields(line string) map[string]string {
+	fields := make(map[string]string)
+	for {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			return fields
+		}
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok || key == "" || strings.Contains(key, " ") {
+			return nil
+		}
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil
+			}
+			fields[key], _ = strconv.Unquote(quoted)
+			line = rest[len(quoted):]
+			continue
+		}
+		value, remaining, _ := strings.Cut(rest, " ")
+		fields[key] = value
+		line = remaining
+	}
+}
+
+// legacyLogLevel 根据旧版本日志的图标返回级别
+func legacyLogLevel(prefix string) string {
+	switch {
+	case strings.HasPrefix(prefix, "✅"):
+		return "SUCCESS"
+	case strings.HasPrefix(prefix, "⚠"):
+		return "WARN"
+	case strings.HasPrefix(prefix, "❌"):
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// queryLogs 返回满足条件的日志
+func (app *App) queryLogs(q logQuery) ([]LogEntry, error) {
+	entries, err := app.readLogEntries()
+	if err != nil {
+		return nil, err
+	}
+	var matched []LogEntry
+	for _, e := range entries {
+		if q.match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if q.tail > 0 && len(matched) > q.tail {
+		matched = matched[len(matched)-q.tail:]
+	}
+	return matched, nil
+}
+
+// printLogEntry 以文本形式输出一条日志
+func printLogEntry(e LogEntry) {
+	run := ""
+	if id := e.Attrs[logKeyRun]; id != "" {
+		run = " [" + id + "]"
+	}
+	fmt.Printf("%s %-7s%s %s\n", e.Time.Local().Format("2006-01-02 15:04:05"), e.Level, run, e.Message)
+}
+
+// showLogs 显示满足条件的日志
+func (app *App) showLogs(q logQuery) error {
+	entries, err := app.queryLogs(q)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		app.logWithLevelOpt(INFO, false, "没有符合条件的日志")
+		return nil
+	}
+
+	fmt.Printf("\n日志（%s，共 %d 条）:\n", app.logDir, len(entries))
+	fmt.Println(strings.Repeat("-", 80))
+	for _, e := range entries {
+		printLogEntry(e)
+	}
+	fmt.Println(strings.Repeat("-", 80))
+	return nil
+}
+
+// runSummaries 按运行 ID 汇总日志，按开始时间排序
+func runSummaries(entries []LogEntry) []RunSummary {
+	var order []string
+	runs := make(map[string]*RunSummary)
+	for _, e := range entries {
+		id := e.Attrs[logKeyRun]
+		if id == "" {
+			continue
+		}
+		run, ok := runs[id]
+		if !ok {
+			run = &RunSummary{ID: id, PID: e.Attrs["pid"], Started: e.Time, Outcome: runOutcomeUnfinished}
+			runs[id] = run
+			order = append(order, id)
+		}
+		if entryLevel(e) == slog.LevelWarn {
+			run.Warnings++
+		}
+		if e.Attrs[logKeyEvent] != runEventEnd {
+			continue
+		}
+		run.Finished = e.Time
+		run.Outcome = e.Attrs[logKeyOutcome]
+		run.Source = e.Attrs[logKeySource]
+		run.Error = e.Attrs[logKeyError]
+		run.Entries, _ = strconv.Atoi(e.Attrs[logKeyEntries])
+		run.DurationMs, _ = strconv.ParseInt(e.Attrs[logKeyDurationMs], 10, 64)
+	}
+
+	summaries := make([]RunSummary, 0, len(order))
+	for _, id := range order {
+		summaries = append(summaries, *runs[id])
+	}
+	sort.SliceStable(summaries, func(i, j int) bool { return summaries[i].Started.Before(summaries[j].Started) })
+	return summaries
+}
+
+// queryRuns 返回开始时间满足条件的运行摘要
+func (app *App) queryRuns(q logQuery) ([]RunSummary, error) {
+	entries, err := app.readLogEntries()
+	if err != nil {
+		return nil, err
+	}
+	var runs []RunSummary
+	for _, run := range runSummaries(entries) {
+		if !q.since.IsZero() && run.Started.Before(q.since) {
+			continue
+		}
+		if !q.until.IsZero() && !run.Started.Before(q.until) {
+			continue
+		}
+		if q.run != "" && run.ID != q.run {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if q.tail > 0 && len(runs) > q.tail {
+		runs = runs[len(runs)-q.tail:]
+	}
+	return runs, nil
+}
+
+// runOutcomeText 返回更新结果的中文说明
+func runOutcomeText(outcome string) string {
+	switch outcome {
+	case runOutcomeSuccess:
+		return "成功"
+	case runOutcomeFailed:
+		return "失败"
+	case runOutcomeRolledBack:
+		return "已回滚"
+	case runOutcomeUnfinished:
+		return "未结束"
+	default:
+		return outcome
+	}
+}
+
+// showRuns 显示每次更新的摘要
+func (app *App) showRuns(q logQuery) error {
+	runs, err := app.queryRuns(q)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		app.logWithLevelOpt(INFO, false, "没有找到更新记录")
+		return nil
+	}
+
+	fmt.Println("\n更新记录:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "开始时间\t结果\t耗时\t记录数\t警告\t数据源\t运行 ID")
+	for _, run := range runs {
+		duration, source := "-", run.Source
+		if run.Outcome != runOutcomeUnfinished {
+			duration = (time.Duration(run.DurationMs) * time.Millisecond).String()
+		}
+		if source == "" {
+			source = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			run.Started.Local().Format("2006-01-02 15:04:05"),
+			runOutcomeText(run.Outcome), duration, run.Entries, run.Warnings, source, run.ID)
+	}
+	w.Flush()
+	for _, run := range runs {
+		if run.Error != "" {
+			fmt.Printf("  %s: %s\n", run.ID, run.Error)
+		}
+	}
+	fmt.Println("\n使用 logs --run <运行 ID> 查看某次更新的完整日志")
+	return nil
+}
+
+// followLogs 持续输出当前日志文件中新写入的日志，直到收到中断信号
+// 日志文件被轮转后自动切换到新文件
+func (app *App) followLogs(q logQuery, output string) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+
+	if output != outputJSON {
+		fmt.Println("正在跟踪日志，按 Ctrl+C 结束...")
+	}
+
+	var file *os.File
+	var pending string
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	// 首次打开时从文件末尾开始，之前的内容已经显示过
+	fromEnd := true
+	buf := make([]byte, 32*1024)
+	for {
+		if file == nil {
+			f, err := os.Open(app.logPath())
+			if err == nil {
+				if fromEnd {
+					f.Seek(0, io.SeekEnd)
+				}
+				file = f
+			}
+			fromEnd = false
+		}
+
+		if file != nil {
+			for {
+				n, err := file.Read(buf)
+				if n > 0 {
+					pending += string(buf[:n])
+					for {
+						line, rest, ok := strings.Cut(pending, "\n")
+						if !ok {
+							break
+						}
+						pending = rest
+						app.printFollowedLine(line, q, output)
+					}
+				}
+				if err != nil || n == 0 {
+					break
+				}
+			}
+
+			// 文件已被轮转或删除时，读完旧文件后切换到新文件
+			current, err := os.Stat(app.logPath())
+			opened, ferr := file.Stat()
+			if err != nil || ferr != nil || !os.SameFile(current, opened) {
+				file.Close()
+				file = nil
+				pending = ""
+				continue
+			}
+			if offset, err := file.Seek(0, io.SeekCurrent); err == nil && current.Size() < offset {
+				// 文件被截断，从头开始读
+				file.Seek(0, io.SeekStart)
+				pending = ""
+			}
+		}
+
+		select {
+		case <-signals:
+			fmt.Println()
+			return nil
+		case <-time.After(logFollowInterval):
+		}
+	}
+}
+
+// printFollowedLine 输出跟踪到的一行日志
+func (app *App) printFollowedLine(line string, q logQuery, output string) {
+	entry, ok := parseLogLine(line)
+	if !ok || !q.match(entry) {
+		return
+	}
+	if output == outputJSON {
+		printLogEntryJSON(entry)
+		return
+	}
+	printLogEntry(entry)
+}
+
+// printLogEntryJSON 以一行 JSON 输出一条日志，用于跟踪日志时的 --output json
+func printLogEntryJSON(e LogEntry) {
+	if data, err := json.Marshal(e); err == nil {
+		fmt.Println(string(data))
+	}
+}
+
+// logsMenu 交互式菜单中的日志查看
+func (app *App) logsMenu() error {
+	for {
+		fmt.Println("\n[日志查看]")
+		fmt.Println("1. 最近的日志")
+		fmt.Println("2. 只看警告和错误")
+		fmt.Println("3. 按关键字搜索")
+		fmt.Println("4. 按日期查看")
+		fmt.Println("5. 更新记录")
+		fmt.Println("6. 实时跟踪")
+		fmt.Println("0. 返回")
+		fmt.Print("请输入选项: ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+
+		q := newLogQuery()
+		var err error
+		switch choice {
+		case 1:
+			err = app.showLogs(q)
+		case 2:
+			q.minLevel = slog.LevelWarn
+			err = app.showLogs(q)
+		case 3:
+			fmt.Print("请输入关键字: ")
+			q.keyword = readLine()
+			q.tail = 0
+			err = app.showLogs(q)
+		case 4:
+			fmt.Print("请输入日期（YYYY-MM-DD）: ")
+			date := readLine()
+			if q.since, err = parseLogTime(date, false); err == nil {
+				q.until, err = parseLogTime(date, true)
+			}
+			if err == nil {
+				q.tail = 0
+				err = app.showLogs(q)
+			}
+		case 5:
+			q.tail = defaultRunsTail
+			err = app.showRuns(q)
+		case 6:
+			if err = app.showLogs(q); err == nil {
+				err = app.followLogs(q, outputText)
+			}
+		case 0:
+			return nil
+		default:
+			fmt.Println("无效的选项，请重试")
+		}
+		if err != nil {
+			app.logWithLevelOpt(ERROR, false, "%v", err)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +8,9 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
 )
 
 // checkAndElevateSudo 检查权限并在需要时提权
@@ -118,7 +120,20 @@ func clearScreen() {
 	}
 }
 
+// hasAdminPrivileges 检查当前进程是否具有修改 hosts 文件所需的权限
+func hasAdminPrivileges() (bool, error) {
+	if runtime.GOOS == "windows" {
+		return isWindowsAdmin()
+	}
+	return os.Geteuid() == 0 || os.Getenv("SUDO_UID") != "", nil
+}
+
 func main() {
+	// 带参数运行时执行非交互式子命令
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	// 检查权限并在需要时提权
 	if err := checkAndElevateSudo(); err != nil {
 		fmt.Printf("错误: %v\n", err)
@@ -127,7 +142,7 @@ func main() {
 	}
 
 	clearScreen() // 启动时先清屏
-	fmt.Println(banner)
+	fmt.Print(banner)
 
 	app, err := NewApp()
 	if err != nil {
@@ -157,7 +172,7 @@ func main() {
 			} else {
 				fmt.Println("4.  切换自动更新")
 			}
-			fmt.Println("5.  修改更新间隔")
+			fmt.Println("5.  修改更新计划")
 
 			fmt.Println("\n[系统工具]")
 			fmt.Println("6.  测试网络连接")
@@ -165,6 +180,12 @@ func main() {
 			fmt.Println("8.  查看更新日志")
 			fmt.Println("9.  打开配置目录")
 			fmt.Println("10. 系统诊断")
+
+			fmt.Println("\n[高级功能]")
+			fmt.Println("13. 管理更新源")
+			fmt.Println("14. 备份管理")
+			fmt.Println("15. 更新历史")
+			fmt.Println("16. 导入/导出配置")
 		}
 
 		fmt.Println("\n[系统]")
@@ -174,7 +195,7 @@ func main() {
 		fmt.Println("12. 🐙 访问项目主页")
 
 		fmt.Println("\n0.  退出程序")
-		fmt.Printf("\n请输入选项 (0-12 或 q 退出): ")
+		fmt.Printf("\n请输入选项 (0-%d 或 q 退出): ", MaxMenuOption)
 
 		// 读取用户输入
 		var input string
@@ -196,7 +217,7 @@ func main() {
 		}
 
 		// 在未安装状态下限制某些选项的访问
-		if !installed && (choice >= 2 && choice <= 10) {
+		if !installed && (choice >= 2 && choice <= 10 || choice >= 13) {
 			fmt.Println("\n❌ 请先安装程序才能使用该功能")
 			waitForEnter()
 			continue
@@ -226,9 +247,9 @@ func main() {
 				log.Printf("切换自动更新失败: %v", err)
 			}
 			waitForEnter()
-		case 5: // 修改更新间隔
+		case 5: // 修改更新计划
 			if err := app.changeUpdateInterval(); err != nil {
-				log.Printf("修改更新间隔失败: %v", err)
+				log.Printf("修改更新计划失败: %v", err)
 			}
 			waitForEnter()
 		case 6: // 测试网络连接
@@ -242,7 +263,7 @@ func main() {
 			}
 			waitForEnter()
 		case 8: // 查看更新日志
-			if err := app.showUpdateLogs(); err != nil {
+			if err := app.logsMenu(); err != nil {
 				log.Printf("查看日志失败: %v", err)
 			}
 			waitForEnter()
@@ -266,6 +287,26 @@ func main() {
 				log.Printf("打开项目主页失败: %v", err)
 			}
 			waitForEnter()
+		case 13: // 管理更新源
+			if err := app.manageSourcesMenu(); err != nil {
+				log.Printf("管理更新源失败: %v", err)
+			}
+			waitForEnter()
+		case 14: // 备份管理
+			if err := app.manageBackupsMenu(); err != nil {
+				log.Printf("备份管理失败: %v", err)
+			}
+			waitForEnter()
+		case 15: // 更新历史
+			if err := app.historyMenu(); err != nil {
+				log.Printf("查看更新历史失败: %v", err)
+			}
+			waitForEnter()
+		case 16: // 导入/导出配置
+			if err := app.configBundleMenu(); err != nil {
+				log.Printf("导入/导出配置失败: %v", err)
+			}
+			waitForEnter()
 		case 0: // 退出
 			fmt.Println("感谢使用，再见！")
 			return
@@ -283,16 +324,20 @@ func NewApp() (*App, error) {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	baseDir := filepath.Join(homeDir, ".github-hosts")
-	app := &App{
-		baseDir:    baseDir,
-		configFile: filepath.Join(baseDir, "config.json"),
-		backupDir:  filepath.Join(baseDir, "backups"),
-		logDir:     filepath.Join(baseDir, "logs"),
-		logger:     log.New(os.Stdout, "", log.LstdFlags),
-	}
+	return newAppWithBaseDir(filepath.Join(homeDir, ".github-hosts")), nil
+}
 
-	return app, nil
+// newAppWithBaseDir 使用指定的基础目录创建应用实例
+func newAppWithBaseDir(baseDir string) *App {
+	return &App{
+		baseDir:     baseDir,
+		configFile:  filepath.Join(baseDir, "config.json"),
+		backupDir:   filepath.Join(baseDir, "backups"),
+		logDir:      filepath.Join(baseDir, "logs"),
+		probeFile:   filepath.Join(baseDir, "probe.json"),
+		daemonFile:  filepath.Join(baseDir, "daemon.json"),
+		historyFile: filepath.Join(baseDir, "history.jsonl"),
+	}
 }
 
 // openGitHubRepo 打开项目主页
@@ -319,27 +364,12 @@ func (app *App) openGitHubRepo() error {
 	return nil
 }
 
-// loadConfig 加载配置文件
-func (app *App) loadConfig() (*Config, error) {
-	data, err := os.ReadFile(app.configFile)
-	if err != nil {
-		return nil, err
-	}
-
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, err
-	}
-
-	return &config, nil
-}
-
 // waitForEnter 等待用户按回车并重新显示界面
 func waitForEnter() {
 	fmt.Print("\n按回车键继续...")
-	fmt.Scanln()        // 等待用户按下回车键
-	clearScreen()       // 清空控制台
-	fmt.Println(banner) // 重新显示 banner
+	fmt.Scanln()      // 等待用户按下回车键
+	clearScreen()     // 清空控制台
+	fmt.Print(banner) // 重新显示 banner
 }
 
 // checkInstallStatus 检查程序安装状态
@@ -348,8 +378,7 @@ func (app *App) checkInstallStatus() (bool, *InstallStatus) {
 		IsInstalled:    false,
 		AutoUpdate:     false,
 		UpdateInterval: 0,
-		LastUpdate:     "",
-		Version:        "v1.0.0", // 当前程序版本
+		Version:        "v" + appVersion,
 	}
 
 	// 检查配置文件是否存在
@@ -358,33 +387,50 @@ func (app *App) checkInstallStatus() (bool, *InstallStatus) {
 		status.IsInstalled = true
 		status.AutoUpdate = config.AutoUpdate
 		status.UpdateInterval = config.UpdateInterval
+		status.Schedule = config.schedule().String()
 
-		// 获取最后更新时间
-		if stat, err := os.Stat(app.configFile); err == nil {
-			status.LastUpdate = stat.ModTime().Format("2006-01-02 15:04:05")
-		}
+		// 最后一次成功更新的时间，修改配置不会改变它
+		status.LastUpdate = config.LastUpdate
 	}
 
 	return status.IsInstalled, status
 }
 
+// installReport 收集安装状态，供菜单和 status 命令使用
+func (app *App) installReport() InstallReport {
+	installed, status := app.checkInstallStatus()
+	report := InstallReport{
+		Installed:      installed,
+		AutoUpdate:     status.AutoUpdate,
+		UpdateInterval: status.UpdateInterval,
+		Schedule:       status.Schedule,
+		Version:        status.Version,
+	}
+	report.LastUpdate = status.LastUpdate
+
+	// 检查 hosts 文件中的 GitHub 记录数量
+	report.EntryCount, _ = app.countGitHubHosts()
+	return report
+}
+
 // displayInstallStatus 显示安装状态
 func (app *App) displayInstallStatus() {
-	installed, status := app.checkInstallStatus()
+	status := app.installReport()
 
 	fmt.Println("\n=== 系统状态 ===")
-	if installed {
+	if status.Installed {
 		fmt.Println("📦 安装状态: ✅ 已安装")
 		fmt.Printf("🔄 自动更新: %s\n", formatBool(status.AutoUpdate))
 		if status.AutoUpdate {
-			fmt.Printf("⏱️  更新间隔: %d 小时\n", status.UpdateInterval)
+			fmt.Printf("⏱️  更新计划: %s\n", status.Schedule)
+		}
+		if status.LastUpdate.IsZero() {
+			fmt.Println("🕒 上次更新: 尚未成功更新")
+		} else {
+			fmt.Printf("🕒 上次更新: %s\n", status.LastUpdate.Local().Format("2006-01-02 15:04:05"))
 		}
-		fmt.Printf("🕒 上次更新: %s\n", status.LastUpdate)
 		fmt.Printf("📌 程序版本: %s\n", status.Version)
-
-		// 检查 hosts 文件中的 GitHub 记录数量
-		count, _ := app.countGitHubHosts()
-		fmt.Printf("📝 GitHub Hosts 记录数: %d\n", count)
+		fmt.Printf("📝 GitHub Hosts 记录数: %d\n", status.EntryCount)
 	} else {
 		fmt.Println("📦 安装状态: ❌ 未安装")
 		fmt.Println("💡 提示: 请选择选项 1 进行安装")
@@ -400,21 +446,14 @@ func formatBool(b bool) string {
 	return "❌ 已关闭"
 }
 
-// countGitHubHosts 统计 hosts 文件中的 GitHub 相关记录数量
+// countGitHubHosts 统计 hosts 文件管理区块中的记录数量
 func (app *App) countGitHubHosts() (int, error) {
 	content, err := os.ReadFile(hostsFile)
 	if err != nil {
 		return 0, err
 	}
 
-	count := 0
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "github") || strings.Contains(line, "githubusercontent") {
-			count++
-		}
-	}
-	return count, nil
+	return len(hostsfile.Parse(content).Entries()), nil
 }
 
 // InstallStatus 安装状态结构体
@@ -422,7 +461,8 @@ type InstallStatus struct {
 	IsInstalled    bool
 	AutoUpdate     bool
 	UpdateInterval int
-	LastUpdate     string
+	Schedule       string    // 更新计划的文字描述
+	LastUpdate     time.Time // 上次成功更新的时间，从未成功时为零值
 	Version        string
 }
 
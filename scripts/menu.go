@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
 )
 
 // showHostsContent 显示 hosts 文件内容
@@ -55,109 +55,225 @@ func (app *App) openConfigDir() error {
 	return nil
 }
 
-// showUpdateLogs 显示更新日志
-func (app *App) showUpdateLogs() error {
-	logFile := filepath.Join(app.logDir, fmt.Sprintf("update_%s.log", time.Now().Format("20060102")))
+// checkStatus 检查系统状态
+func (app *App) checkStatus() error {
+	app.logWithLevel(INFO, "开始检查系统状态...")
+	app.printStatusReport(app.statusReport())
+	return nil
+}
+
+// statusReport 收集系统状态
+func (app *App) statusReport() *StatusReport {
+	report := &StatusReport{
+		reportHeader: newReportHeader(),
+		Install:      app.installReport(),
+		Hosts:        hostsReport(),
+		Scheduler:    app.schedulerReport(),
+		Daemon:       app.daemonReport(),
+		History:      app.historyStatus(),
+		Directories:  app.dirReports(),
+		Backups:      app.backupReport(),
+	}
+
+	if config, err := app.loadConfig(); err != nil {
+		report.ConfigError = err.Error()
+	} else {
+		report.Config = newConfigReport(config)
+	}
+	if probe, err := app.loadProbeReport(); err == nil {
+		report.Probe = probe
+	}
+	return report
+}
 
-	content, err := os.ReadFile(logFile)
+// hostsReport 检查 hosts 文件
+func hostsReport() HostsReport {
+	report := HostsReport{Path: hostsFile}
+	info, err := os.Stat(hostsFile)
 	if err != nil {
-		if os.IsNotExist(err) {
-			app.logWithLevel(INFO, "今日暂无更新日志")
-			return nil
-		}
-		return fmt.Errorf("读取日志文件失败: %w", err)
+		report.Error = err.Error()
+		return report
 	}
+	report.ModTime = info.ModTime()
 
-	fmt.Println("\n最近的更新日志:")
-	fmt.Println(strings.Repeat("-", 80))
-	fmt.Println(string(content))
-	fmt.Println(strings.Repeat("-", 80))
-	return nil
+	content, err := os.ReadFile(hostsFile)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	f := hostsfile.Parse(content)
+	report.SizeBytes = int64(len(content))
+	report.HasBlock = f.HasBlock()
+	report.EntryCount = len(f.Entries())
+	return report
 }
 
-// checkStatus 检查系统状态
-func (app *App) checkStatus() error {
-	app.logWithLevel(INFO, "开始检查系统状态...")
+// schedulerReport 检查定时任务状态
+func (app *App) schedulerReport() SchedulerReport {
+	report := SchedulerReport{Platform: runtime.GOOS}
+	if backend, detail := activeScheduler(); backend != nil {
+		report.Configured = true
+		report.Backend = backend.name()
+		report.Detail = detail
+		return report
+	}
 
-	// 1. 检查配置文件
-	config, err := app.loadConfig()
+	// 未安装时显示将会使用的后端
+	config, err := app.loadConfigOrDefault()
+	if err != nil {
+		report.Detail = err.Error()
+		return report
+	}
+	backend, err := selectScheduler(config.schedulerName())
+	if err != nil {
+		report.Detail = err.Error()
+		return report
+	}
+	report.Backend = backend.name()
+	report.Detail = "未安装"
+	return report
+}
+
+// dirReports 检查程序目录的权限
+func (app *App) dirReports() []DirReport {
+	var reports []DirReport
+	for _, dir := range []string{app.baseDir, app.backupDir, app.logDir} {
+		report := DirReport{Path: dir, Writable: true}
+		if err := app.checkDirPermissions(dir); err != nil {
+			report.Writable = false
+			report.Error = err.Error()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// backupReport 收集备份清单，按文件名从新到旧排列
+func (app *App) backupReport() BackupReport {
+	report := BackupReport{Files: []BackupFileReport{}}
+	backups, err := app.backupInfos()
 	if err != nil {
-		app.logWithLevel(ERROR, "配置文件检查失败: %v", err)
+		report.Error = err.Error()
+		return report
+	}
+
+	for _, backup := range backups {
+		file := BackupFileReport{Name: backup.name, SizeBytes: backup.size}
+		if meta, err := app.loadBackupMeta(backup.name); err == nil {
+			file.Meta = meta
+		}
+		report.Files = append(report.Files, file)
+	}
+	report.Count = len(backups)
+	if len(backups) > 0 {
+		report.Latest = backups[0].name
+	}
+	return report
+}
+
+// printStatusReport 以文本形式输出系统状态
+func (app *App) printStatusReport(report *StatusReport) {
+	// 1. 检查配置文件
+	if report.Config == nil {
+		app.logWithLevel(ERROR, "配置文件检查失败: %s", report.ConfigError)
 	} else {
+		config := report.Config
 		app.logWithLevel(INFO, "配置文件状态:")
-		app.logWithLevel(INFO, "  • 更新间隔: %d 分钟", config.UpdateInterval)
+		app.logWithLevel(INFO, "  • 更新计划: %s", config.Schedule)
 		app.logWithLevel(INFO, "  • 自动更新: %s", map[bool]string{true: "已启用", false: "已禁用"}[config.AutoUpdate])
-		app.logWithLevel(INFO, "  • 最后更新: %s", config.LastUpdate.Local().Format("2006-01-02 15:04:05"))
+		if config.LastUpdate.IsZero() {
+			app.logWithLevel(INFO, "  • 最后更新: 尚未成功更新")
+		} else {
+			app.logWithLevel(INFO, "  • 最后更新: %s", config.LastUpdate.Local().Format("2006-01-02 15:04:05"))
+		}
 		app.logWithLevel(INFO, "  • 版本: %s", config.Version)
+		app.logWithLevel(INFO, "  • 更新后健康检查: %s", map[bool]string{true: "已启用", false: "已禁用"}[config.GuardEnabled])
+		if rb := config.LastRollback; rb != nil {
+			app.logWithLevel(WARNING, "  • 最近一次自动回滚: %s（%d/%d 个域名失败: %s，已恢复 %s）",
+				rb.Time.Local().Format("2006-01-02 15:04:05"), rb.Failed, rb.Total, strings.Join(rb.FailedDomains, ", "), rb.Backup)
+		}
 	}
 
-	// 2. 检查 hosts 文件
-	if _, err := os.Stat(hostsFile); err != nil {
-		app.logWithLevel(ERROR, "hosts 文件检查失败: %v", err)
-	} else {
-		content, err := os.ReadFile(hostsFile)
-		if err != nil {
-			app.logWithLevel(ERROR, "读取 hosts 文件失败: %v", err)
-		} else {
-			lines := strings.Split(string(content), "\n")
-			githubCount := 0
-			for _, line := range lines {
-				if strings.Contains(strings.ToLower(line), "github") {
-					githubCount++
-				}
+	if h := report.History; h != nil {
+		app.logWithLevel(INFO, "更新记录:")
+		if h.Error != "" {
+			app.logWithLevel(WARNING, "  • 读取更新历史失败: %s", h.Error)
+		}
+		if last := h.LastAttempt; last != nil {
+			level := SUCCESS
+			if last.Result != runOutcomeSuccess {
+				level = WARNING
 			}
-			app.logWithLevel(INFO, "hosts 文件状态:")
-			app.logWithLevel(INFO, "  • 文件大小: %.2f KB", float64(len(content))/1024)
-			app.logWithLevel(INFO, "  • GitHub 相关记录数: %d", githubCount)
+			app.logWithLevel(level, "  • 上次尝试: %s（%s，%s）", last.Time.Local().Format("2006-01-02 15:04:05"),
+				triggerText(last.Trigger), runOutcomeText(last.Result))
+			if last.Error != "" {
+				app.logWithLevel(WARNING, "    %s", last.Error)
+			}
+		}
+		if success := h.LastSuccess; success != nil {
+			app.logWithLevel(INFO, "  • 上次成功: %s（%s，%d 处变化）", success.Time.Local().Format("2006-01-02 15:04:05"),
+				success.Source, len(success.Changes))
+		} else if h.LastAttempt != nil {
+			app.logWithLevel(WARNING, "  • 上次成功: 更新历史中没有成功的更新")
 		}
 	}
 
+	// 2. 检查 hosts 文件
+	if report.Hosts.Error != "" {
+		app.logWithLevel(ERROR, "hosts 文件检查失败: %s", report.Hosts.Error)
+	} else {
+		app.logWithLevel(INFO, "hosts 文件状态:")
+		app.logWithLevel(INFO, "  • 文件大小: %.2f KB", float64(report.Hosts.SizeBytes)/1024)
+		app.logWithLevel(INFO, "  • 管理区块: %s", map[bool]string{true: "已存在", false: "不存在"}[report.Hosts.HasBlock])
+		app.logWithLevel(INFO, "  • GitHub 相关记录数: %d", report.Hosts.EntryCount)
+	}
+
+	app.showProbeReport()
+
 	// 3. 检查定时任务状态
 	app.logWithLevel(INFO, "定时任务状态:")
-	switch runtime.GOOS {
-	case "darwin":
-		cmd := exec.Command("launchctl", "list", "com.github.hosts")
-		if err := cmd.Run(); err == nil {
-			app.logWithLevel(SUCCESS, "  • 定时任务运行正常")
-		} else {
-			app.logWithLevel(WARNING, "  • 定时任务未运行")
+	if report.Scheduler.Configured {
+		app.logWithLevel(SUCCESS, "  • 定时任务配置正常 (%s: %s)", report.Scheduler.Backend, report.Scheduler.Detail)
+	} else {
+		app.logWithLevel(WARNING, "  • 定时任务未配置 (%s: %s)", report.Scheduler.Backend, report.Scheduler.Detail)
+	}
+
+	if d := report.Daemon; d != nil {
+		app.logWithLevel(INFO, "守护进程状态:")
+		switch {
+		case !d.Alive:
+			app.logWithLevel(INFO, "  • 未运行（上次 PID %d，状态 %s）", d.PID, d.Status)
+		case d.Healthy:
+			app.logWithLevel(SUCCESS, "  • 运行中（PID %d，%s）", d.PID, d.Schedule)
+		default:
+			app.logWithLevel(WARNING, "  • 运行中但连续 %d 次更新失败（PID %d）: %s", d.ConsecutiveFailures, d.PID, d.LastError)
 		}
-	case "windows":
-		cmd := exec.Command("schtasks", "/query", "/tn", windowsTaskName)
-		if err := cmd.Run(); err == nil {
-			app.logWithLevel(SUCCESS, "  • 定时任务运行正常")
-		} else {
-			app.logWithLevel(WARNING, "  • 定时任务未运行")
+		if !d.LastSuccess.IsZero() {
+			app.logWithLevel(INFO, "  • 上次成功更新: %s", d.LastSuccess.Local().Format("2006-01-02 15:04:05"))
 		}
-	case "linux":
-		if _, err := os.Stat(linuxCronPath); err == nil {
-			app.logWithLevel(SUCCESS, "  • 定时任务配置正常")
-		} else {
-			app.logWithLevel(WARNING, "  • 定时任务配置不存在")
+		if d.Alive && !d.NextRun.IsZero() {
+			app.logWithLevel(INFO, "  • 下次更新: %s", d.NextRun.Local().Format("2006-01-02 15:04:05"))
 		}
 	}
 
 	// 4. 检查目录权限
 	app.logWithLevel(INFO, "目录权限检查:")
-	dirs := []string{app.baseDir, app.backupDir, app.logDir}
-	for _, dir := range dirs {
-		if err := app.checkDirPermissions(dir); err != nil {
-			app.logWithLevel(WARNING, "  • %s: %v", dir, err)
+	for _, dir := range report.Directories {
+		if dir.Writable {
+			app.logWithLevel(SUCCESS, "  • %s: 权限正常", dir.Path)
 		} else {
-			app.logWithLevel(SUCCESS, "  • %s: 权限正常", dir)
+			app.logWithLevel(WARNING, "  • %s: %s", dir.Path, dir.Error)
 		}
 	}
 
 	// 5. 检查备份状态
-	if backups, err := app.listBackups(); err != nil {
-		app.logWithLevel(ERROR, "备份检查失败: %v", err)
+	if report.Backups.Error != "" {
+		app.logWithLevel(ERROR, "备份检查失败: %s", report.Backups.Error)
 	} else {
 		app.logWithLevel(INFO, "备份状态:")
-		app.logWithLevel(INFO, "  • 备份文件数量: %d", len(backups))
-		if len(backups) > 0 {
-			app.logWithLevel(INFO, "  • 最新备份: %s", backups[len(backups)-1])
+		app.logWithLevel(INFO, "  • 备份文件数量: %d", report.Backups.Count)
+		if report.Backups.Latest != "" {
+			app.logWithLevel(INFO, "  • 最新备份: %s", report.Backups.Latest)
 		}
 	}
-
-	return nil
 }
@@ -1,181 +1,143 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
-	"net"
-	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 	"text/tabwriter"
-	"time"
 )
 
-// testConnection 测试网络连接
+// testConnection 使用默认探测方式测试网络连接
 func (app *App) testConnection() error {
-	app.logWithLevel(INFO, "开始网络连接测试...")
-	fmt.Println("\n=== 连接测试结果 ===")
-
-	// 读取 hosts 文件
-	content, err := os.ReadFile(hostsFile)
-	if err != nil {
-		fmt.Printf("\n❌ 严重错误：无法读取 hosts 文件\n")
-		fmt.Printf("❌ 错误详情：%v\n", err)
-		return err
-	}
-
-	// 解析 hosts 文件中的 GitHub 相关记录
-	var tests []struct {
-		name string
-		ip   string
-		host string
-	}
-
-	startMarker := "# ===== GitHub Hosts Start ====="
-	endMarker := "# ===== GitHub Hosts End ====="
-	inGithubSection := false
-	scanner := bufio.NewScanner(strings.NewReader(string(content)))
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		if line == startMarker {
-			inGithubSection = true
-			continue
-		}
-		if line == endMarker {
-			inGithubSection = false
-			continue
-		}
+	return app.testConnectionWith(defaultConnTestOptions())
+}
 
-		if inGithubSection && line != "" && !strings.HasPrefix(line, "#") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				tests = append(tests, struct {
-					name string
-					ip   string
-					host string
-				}{
-					name: fields[1],
-					ip:   fields[0],
-					host: fields[1],
-				})
-			}
-		}
-	}
+// testConnectionWith 使用指定的探测方式测试网络连接并输出结果
+func (app *App) testConnectionWith(opts connTestOptions) error {
+	app.logWithLevel(INFO, "开始网络连接测试（%s 探测，并发 %d）...", opts.probe, opts.concurrency)
+	fmt.Println("\n=== 连接测试结果 ===")
 
-	if len(tests) == 0 {
-		fmt.Printf("\n❌ 错误：在 hosts 文件中未找到 GitHub 相关记录\n")
-		return fmt.Errorf("no github hosts found")
-	}
+	report := app.connectionReport(opts)
+	return app.printConnectionReport(report)
+}
 
-	// 创建 HTTP 客户端
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSHandshakeTimeout:   5 * time.Second,
-			ResponseHeaderTimeout: 5 * time.Second,
-			DisableKeepAlives:     true,
-		},
+// printConnectionReport 以表格形式输出连接测试结果，有失败项时返回错误
+func (app *App) printConnectionReport(report *ConnectionReport) error {
+	if report.Error != "" {
+		fmt.Printf("\n❌ 错误：%s\n", report.Error)
+		return report.err()
 	}
 
 	// 使用 tabwriter 创建表格
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
 	// 输出表头
-	fmt.Fprintf(w, "\n%s\t%s\t%s\t%s\t%s\n",
+	fmt.Fprintf(w, "\n%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 		"域名",
 		"状态",
 		"响应时间",
-		"当前解析IP",
-		"期望IP")
-	fmt.Fprintln(w, strings.Repeat("-", 100))
-
-	// 测试结果统计
-	var (
-		successCount = 0
-		failCount    = 0
-	)
-
-	// 测试每个域名
-	for _, test := range tests {
-		start := time.Now()
-
-		// 获取实际 DNS 解析结果
-		actualIP := ""
-		addrs, err := net.LookupHost(test.host)
-		if err != nil {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-				test.host,
-				"✗ DNS失败",
-				"-",
-				"解析失败",
-				test.ip)
-			fmt.Printf("❌ DNS 解析失败: %v\n", err)
-			failCount++
-			continue
-		}
-		if len(addrs) > 0 {
-			actualIP = addrs[0]
-		}
-
-		// 测试连接
-		resp, err := client.Get("https://" + test.host)
-		elapsed := time.Since(start)
-
-		if err != nil {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-				test.host,
-				"✗ 连接失败",
-				fmt.Sprintf("%.2fs", elapsed.Seconds()),
-				actualIP,
-				test.ip)
-			fmt.Printf("❌ 连接失败: %v\n", err)
-			failCount++
-			continue
+		"hosts IP",
+		"IP可达",
+		"证书",
+		"系统解析IP")
+	fmt.Fprintln(w, strings.Repeat("-", 110))
+
+	for _, r := range report.Results {
+		latency := "-"
+		if r.LatencyMs > 0 {
+			latency = fmt.Sprintf("%.2fs", r.LatencyMs/1000)
 		}
-		defer resp.Body.Close()
-
-		// 检查 IP 匹配和连接状态
-		status := "✓ 正常"
-		if actualIP != test.ip {
-			status = "! IP不匹配"
-			fmt.Printf("⚠️  %s 的 IP 不匹配！当前: %s, 期望: %s\n", test.host, actualIP, test.ip)
-			failCount++
-		} else if resp.StatusCode != http.StatusOK {
-			status = fmt.Sprintf("! 状态%d", resp.StatusCode)
-			fmt.Printf("⚠️  %s 返回异常状态码: %d\n", test.host, resp.StatusCode)
-			failCount++
-		} else {
-			successCount++
+		resolved := r.ResolvedIP
+		if r.ResolveError != "" {
+			resolved = "解析失败"
 		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			test.host,
-			status,
-			fmt.Sprintf("%.2fs", elapsed.Seconds()),
-			actualIP,
-			test.ip)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Domain, testStatusText(r), latency, r.ExpectedIP, checkMark(r.Reachable), checkMark(r.CertValid), resolved)
 	}
 
-	fmt.Fprintln(w, strings.Repeat("-", 100))
+	fmt.Fprintln(w, strings.Repeat("-", 110))
 	w.Flush()
 
+	// 输出失败详情
+	for _, r := range report.Results {
+		switch r.Status {
+		case "dns_failed":
+			fmt.Printf("❌ %s DNS 解析失败: %s\n", r.Domain, r.Error)
+		case "connect_failed":
+			fmt.Printf("❌ %s 连接失败: %s\n", r.Domain, r.Error)
+		case "tls_failed":
+			fmt.Printf("❌ %s TLS 握手失败: %s\n", r.Domain, r.Error)
+		case "cert_invalid":
+			fmt.Printf("❌ %s 的证书对该域名无效: %s\n", r.Domain, r.CertError)
+		case "ip_mismatch":
+			fmt.Printf("⚠️  %s 的 hosts 记录可用，但系统解析为 %s（期望 %s），可能是 DNS 缓存未刷新\n", r.Domain, r.ResolvedIP, r.ExpectedIP)
+		case "http_status":
+			fmt.Printf("⚠️  %s 返回异常状态码: %d\n", r.Domain, r.HTTPStatus)
+		}
+	}
+
 	// 输出总结
 	fmt.Printf("\n测试总结:\n")
-	fmt.Printf("总计测试: %d\n", len(tests))
-	if successCount > 0 {
-		fmt.Printf("✅ 成功: %d\n", successCount)
+	fmt.Printf("总计测试: %d\n", report.Total)
+	if report.Passed > 0 {
+		fmt.Printf("✅ 成功: %d\n", report.Passed)
+		fmt.Printf("⏱️  延迟: p50 %.0fms, p95 %.0fms\n", report.P50Ms, report.P95Ms)
+	}
+	if report.Failed > 0 {
+		fmt.Printf("❌ 失败: %d\n", report.Failed)
+		fmt.Printf("\n⚠️  警告：检测到 %d 个问题，建议重新执行更新操作\n", report.Failed)
+		return report.err()
+	}
+
+	fmt.Printf("\n✅ 太好了！所有测试都通过了\n")
+	return nil
+}
+
+// checkMark 返回检查项在表格中的显示文本，未检查时显示 -
+func checkMark(ok *bool) string {
+	switch {
+	case ok == nil:
+		return "-"
+	case *ok:
+		return "✓"
+	default:
+		return "✗"
 	}
-	if failCount > 0 {
-		fmt.Printf("❌ 失败: %d\n", failCount)
-		fmt.Printf("\n⚠️  警告：检测到 %d 个问题，建议重新执行更新操作\n", failCount)
-	} else {
-		fmt.Printf("\n✅ 太好了！所有测试都通过了\n")
+}
+
+// testStatusText 返回测试状态在表格中的显示文本
+func testStatusText(r DomainTestResult) string {
+	switch r.Status {
+	case "ok":
+		return "✓ 正常"
+	case "dns_failed":
+		return "✗ DNS失败"
+	case "connect_failed":
+		return "✗ 连接失败"
+	case "tls_failed":
+		return "✗ TLS失败"
+	case "cert_invalid":
+		return "✗ 证书无效"
+	case "ip_mismatch":
+		return "! IP不匹配"
+	case "http_status":
+		return fmt.Sprintf("! 状态%d", r.HTTPStatus)
+	default:
+		return r.Status
 	}
+}
 
+// err 根据测试结果返回错误，全部通过时返回 nil
+func (report *ConnectionReport) err() error {
+	if report.Error != "" {
+		return errors.New(report.Error)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d 个域名测试未通过", report.Failed)
+	}
 	return nil
 }
 
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 探测方式
+const (
+	probeModeOff = "off" // 不探测，每个域名使用第一个候选 IP
+	probeModeTCP = "tcp" // 只建立 TCP 连接
+	probeModeTLS = "tls" // TCP 连接后完成 TLS 握手（默认）
+)
+
+const (
+	// defaultProbeAttempts 每个候选 IP 的默认探测次数
+	defaultProbeAttempts = 3
+	// defaultProbeTimeout 单次探测的默认超时
+	defaultProbeTimeout = 3 * time.Second
+	// probeConcurrency 同时探测的候选 IP 数量
+	probeConcurrency = 16
+	// probePort 探测的端口
+	probePort = "443"
+)
+
+// ProbeConfig 候选 IP 探测配置
+type ProbeConfig struct {
+	Mode     string `json:"mode"`               // off, tcp 或 tls
+	Attempts int    `json:"attempts,omitempty"` // 每个候选 IP 的探测次数，0 表示使用默认值
+	Timeout  int    `json:"timeout,omitempty"`  // 单次探测超时（秒），0 表示使用默认值
+}
+
+// probeMode 返回配置的探测方式
+func (c *Config) probeMode() string {
+	if c == nil || c.Probe == nil || c.Probe.Mode == "" {
+		return probeModeTLS
+	}
+	return c.Probe.Mode
+}
+
+// probeAttempts 返回每个候选 IP 的探测次数
+func (c *Config) probeAttempts() int {
+	if c == nil || c.Probe == nil || c.Probe.Attempts <= 0 {
+		return defaultProbeAttempts
+	}
+	return c.Probe.Attempts
+}
+
+// probeTimeout 返回单次探测的超时
+func (c *Config) probeTimeout() time.Duration {
+	if c == nil || c.Probe == nil || c.Probe.Timeout <= 0 {
+		return defaultProbeTimeout
+	}
+	return time.Duration(c.Probe.Timeout) * time.Second
+}
+
+// validateProbeMode 检查探测方式是否受支持
+func validateProbeMode(mode string) error {
+	switch mode {
+	case probeModeOff, probeModeTCP, probeModeTLS:
+		return nil
+	default:
+		return fmt.Errorf("无效的探测方式: %s（可选 off, tcp, tls）", mode)
+	}
+}
+
+// setProbeMode 修改探测方式
+func (app *App) setProbeMode(mode string) error {
+	if err := validateProbeMode(mode); err != nil {
+		return err
+	}
+
+	err := app.modifyConfig(func(config *Config) error {
+		if config.Probe == nil {
+			config.Probe = &ProbeConfig{}
+		}
+		config.Probe.Mode = mode
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	app.logWithLevel(SUCCESS, "探测方式已修改为 %s", mode)
+	return nil
+}
+
+// CandidateResult 单个候选 IP 的探测结果
+type CandidateResult struct {
+	IP        string  `json:"ip"`
+	Attempts  int     `json:"attempts"`
+	Successes int     `json:"successes"`
+	MedianMs  float64 `json:"medianMs"`        // 成功探测的延迟中位数（毫秒）
+	Error     string  `json:"error,omitempty"` // 最近一次失败的原因
+}
+
+// successRate 返回探测成功率
+func (r CandidateResult) successRate() float64 {
+	if r.Attempts == 0 {
+		return 0
+	}
+	return float64(r.Successes) / float64(r.Attempts)
+}
+
+// DomainProbe 单个域名的探测结果，Candidates 按优劣排序
+type DomainProbe struct {
+	Domain     string            `json:"domain"`
+	Selected   string            `json:"selected"`
+	Candidates []CandidateResult `json:"candidates"`
+}
+
+// ProbeReport 一次更新中的探测结果，保存后供 status 显示
+type ProbeReport struct {
+	Time    time.Time     `json:"time"`
+	Mode    string        `json:"mode"`
+	Domains []DomainProbe `json:"domains"`
+}
+
+// prober 对候选 IP 进行 TCP/TLS 探测
+type prober struct {
+	mode     string
+	attempts int
+	timeout  time.Duration
+}
+
+// probe 多次连接候选 IP 的 443 端口，TLS 握手时以域名作为 SNI
+func (p prober) probe(ctx context.Context, domain, ip string) CandidateResult {
+	result := CandidateResult{IP: ip, Attempts: p.attempts}
+	var latencies []time.Duration
+
+	for i := 0; i < p.attempts; i++ {
+		elapsed, err := p.probeOnce(ctx, domain, ip)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Successes++
+		latencies = append(latencies, elapsed)
+	}
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		median := latencies[len(latencies)/2]
+		if len(latencies)%2 == 0 {
+			median = (latencies[len(latencies)/2-1] + median) / 2
+		}
+		result.MedianMs = float64(median.Microseconds()) / 1000
+	}
+	return result
+}
+
+// probeOnce 执行一次探测并返回耗时
+func (p prober) probeOnce(ctx context.Context, domain, ip string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, probePort))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if p.mode == probeModeTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: domain})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return time.Since(start), nil
+}
+
+// rankCandidates 按成功率从高到低、延迟中位数从低到高排序，成绩相同时保持原有顺序
+func rankCandidates(results []CandidateResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		ri, rj := results[i].successRate(), results[j].successRate()
+		if ri != rj {
+			return ri > rj
+		}
+		if results[i].Successes == 0 {
+			return false
+		}
+		return results[i].MedianMs < results[j].MedianMs
+	})
+}
+
+// selectBestEntries 为每个域名选出一个 IP
+// 只有一个候选 IP 的域名直接使用；有多个候选时并发探测并选择最优者，探测方式为 off 时使用第一个候选
+// 返回的探测结果由调用方在 hosts 写入成功后保存，探测方式为 off 时为 nil
+func (app *App) selectBestEntries(config *Config, entries []HostEntry) ([]HostEntry, *ProbeReport) {
+	var domains []string
+	candidates := make(map[string][]string)
+	for _, entry := range entries {
+		ips, ok := candidates[entry.Domain]
+		if !ok {
+			domains = append(domains, entry.Domain)
+		}
+		if !containsString(ips, entry.IP) {
+			candidates[entry.Domain] = append(ips, entry.IP)
+		}
+	}
+
+	mode := config.probeMode()
+	var report *ProbeReport
+	selected := make(map[string]string, len(domains))
+	for _, domain := range domains {
+		selected[domain] = candidates[domain][0]
+	}
+
+	if mode != probeModeOff {
+		p := prober{mode: mode, attempts: config.probeAttempts(), timeout: config.probeTimeout()}
+		report = &ProbeReport{Time: time.Now().UTC(), Mode: mode}
+		report.Domains = app.probeCandidates(p, domains, candidates)
+		for _, d := range report.Domains {
+			selected[d.Domain] = d.Selected
+		}
+		if len(report.Domains) > 0 {
+			app.logWithLevel(INFO, "已探测 %d 个域名的候选 IP 并选择最优地址", len(report.Domains))
+		}
+	}
+
+	best := make([]HostEntry, 0, len(domains))
+	for _, domain := range domains {
+		best = append(best, HostEntry{IP: selected[domain], Domain: domain})
+	}
+	return best, report
+}
+
+// probeCandidates 并发探测有多个候选 IP 的域名
+func (app *App) probeCandidates(p prober, domains []string, candidates map[string][]string) []DomainProbe {
+	var probes []DomainProbe
+	for _, domain := range domains {
+		if ips := candidates[domain]; len(ips) > 1 {
+			probes = append(probes, DomainProbe{Domain: domain, Candidates: make([]CandidateResult, len(ips))})
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for i := range probes {
+		for j, ip := range candidates[probes[i].Domain] {
+			wg.Add(1)
+			go func(d *DomainProbe, j int, ip string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				d.Candidates[j] = p.probe(context.Background(), d.Domain, ip)
+			}(&probes[i], j, ip)
+		}
+	}
+	wg.Wait()
+
+	for i := range probes {
+		d := &probes[i]
+		rankCandidates(d.Candidates)
+		d.Selected = d.Candidates[0].IP
+		if d.Candidates[0].Successes == 0 {
+			app.logWithLevel(WARNING, "%s 的所有候选 IP 均探测失败，使用 %s", d.Domain, d.Selected)
+		}
+	}
+	return probes
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// saveProbeReport 以原子方式保存最近一次的探测结果
+func (app *App) saveProbeReport(report *ProbeReport) error {
+	data, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(app.probeFile, data, 0644)
+}
+
+// loadProbeReport 读取最近一次的探测结果
+func (app *App) loadProbeReport() (*ProbeReport, error) {
+	data, err := os.ReadFile(app.probeFile)
+	if err != nil {
+		return nil, err
+	}
+	var report ProbeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// showProbeReport 在状态检查中显示最近一次的探测结果
+func (app *App) showProbeReport() {
+	report, err := app.loadProbeReport()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			app.logWithLevel(WARNING, "读取探测结果失败: %v", err)
+		}
+		return
+	}
+
+	app.logWithLevel(INFO, "候选 IP 探测结果 (%s, %s):", report.Mode, report.Time.Local().Format("2006-01-02 15:04:05"))
+	if len(report.Domains) == 0 {
+		app.logWithLevel(INFO, "  • 没有需要探测的域名（每个域名只有一个候选 IP）")
+		return
+	}
+	for _, d := range report.Domains {
+		var parts []string
+		for _, c := range d.Candidates {
+			if c.Successes == 0 {
+				parts = append(parts, fmt.Sprintf("%s 失败", c.IP))
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s %.0fms %d/%d", c.IP, c.MedianMs, c.Successes, c.Attempts))
+		}
+		app.logWithLevel(INFO, "  • %s → %s [%s]", d.Domain, d.Selected, strings.Join(parts, ", "))
+	}
+}
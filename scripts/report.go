@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// 命令输出格式
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// reportSchemaVersion JSON 输出的结构版本，字段有不兼容的变化时递增
+const reportSchemaVersion = 1
+
+// validateOutputFormat 检查输出格式是否受支持
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("无效的输出格式: %s（可选 text, json）", format)
+	}
+}
+
+// writeJSON 将结果以缩进的 JSON 输出到标准输出
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// reportHeader 所有 JSON 输出共有的字段
+type reportHeader struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	GeneratedAt   time.Time `json:"generatedAt"`
+}
+
+// newReportHeader 返回当前时间的报告头
+func newReportHeader() reportHeader {
+	return reportHeader{SchemaVersion: reportSchemaVersion, GeneratedAt: time.Now().UTC()}
+}
+
+// InstallReport 安装状态
+type InstallReport struct {
+	Installed      bool      `json:"installed"`
+	AutoUpdate     bool      `json:"autoUpdate"`
+	UpdateInterval int       `json:"updateInterval"`
+	Schedule       string    `json:"schedule,omitempty"`
+	LastUpdate     time.Time `json:"lastUpdate"`
+	Version        string    `json:"version"`
+	EntryCount     int       `json:"entryCount"`
+}
+
+// ConfigReport 配置文件内容
+type ConfigReport struct {
+	UpdateInterval int             `json:"updateInterval"`
+	Schedule       *ScheduleConfig `json:"schedule"`
+	AutoUpdate     bool            `json:"autoUpdate"`
+	LastUpdate     time.Time       `json:"lastUpdate"`
+	Version        string          `json:"version"`
+	Sources        []string        `json:"sources"`
+	LastSource     string          `json:"lastSource,omitempty"`
+	ResolverMode   string          `json:"resolverMode"`
+	ProbeMode      string          `json:"probeMode"`
+	GuardEnabled   bool            `json:"guardEnabled"`
+	LastRollback   *RollbackRecord `json:"lastRollback,omitempty"`
+}
+
+// newConfigReport 从配置生成报告
+func newConfigReport(config *Config) *ConfigReport {
+	schedule := config.schedule()
+	report := &ConfigReport{
+		UpdateInterval: config.UpdateInterval,
+		Schedule:       &schedule,
+		AutoUpdate:     config.AutoUpdate,
+		LastUpdate:     config.LastUpdate,
+		Version:        config.Version,
+		LastSource:     config.LastSource,
+		ResolverMode:   config.resolverMode(),
+		ProbeMode:      config.probeMode(),
+		GuardEnabled:   config.guard().Enabled,
+		LastRollback:   config.LastRollback,
+	}
+	for _, s := range config.hostsSources() {
+		report.Sources = append(report.Sources, s.URL)
+	}
+	return report
+}
+
+// HostsReport hosts 文件状态
+type HostsReport struct {
+	Path       string    `json:"path"`
+	SizeBytes  int64     `json:"sizeBytes"`
+	ModTime    time.Time `json:"modTime"`
+	HasBlock   bool      `json:"hasBlock"`
+	EntryCount int       `json:"entryCount"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// SchedulerReport 定时任务状态
+type SchedulerReport struct {
+	Platform   string `json:"platform"`
+	Backend    string `json:"backend,omitempty"` // systemd, cron, crontab, launchd 或 schtasks
+	Configured bool   `json:"configured"`
+	Detail     string `json:"detail"`
+}
+
+// DirReport 目录权限检查结果
+type DirReport struct {
+	Path     string `json:"path"`
+	Writable bool   `json:"writable"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BackupFileReport 单个备份文件
+type BackupFileReport struct {
+	Name      string      `json:"name"`
+	SizeBytes int64       `json:"sizeBytes"` // 磁盘上（压缩后）的大小
+	Meta      *BackupMeta `json:"meta,omitempty"`
+}
+
+// BackupReport 备份清单
+type BackupReport struct {
+	Count  int                `json:"count"`
+	Latest string             `json:"latest,omitempty"`
+	Files  []BackupFileReport `json:"files"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// DaemonReport 守护进程状态
+type DaemonReport struct {
+	*DaemonState
+	Alive   bool `json:"alive"`   // 状态文件中的进程是否存在
+	Healthy bool `json:"healthy"` // 进程存在且最近一次更新成功
+}
+
+// StatusReport status 命令的输出
+type StatusReport struct {
+	reportHeader
+	Install     InstallReport   `json:"install"`
+	Config      *ConfigReport   `json:"config,omitempty"`
+	ConfigError string          `json:"configError,omitempty"`
+	Hosts       HostsReport     `json:"hosts"`
+	Scheduler   SchedulerReport `json:"scheduler"`
+	Daemon      *DaemonReport   `json:"daemon,omitempty"`
+	History     *HistoryStatus  `json:"history,omitempty"`
+	Directories []DirReport     `json:"directories"`
+	Backups     BackupReport    `json:"backups"`
+	Probe       *ProbeReport    `json:"probe,omitempty"`
+}
+
+// DomainTestResult 单个域名的连接测试结果
+// Status 取值: ok, connect_failed, tls_failed, cert_invalid, http_status, dns_failed, ip_mismatch
+type DomainTestResult struct {
+	Domain         string  `json:"domain"`
+	ExpectedIP     string  `json:"expectedIp"`
+	Status         string  `json:"status"`
+	OK             bool    `json:"ok"`
+	Reachable      *bool   `json:"reachable,omitempty"`    // hosts 中的 IP 是否可达，dns 探测时不检查
+	SystemResolves bool    `json:"systemResolves"`         // 系统解析结果是否包含 hosts 中的 IP
+	ResolvedIP     string  `json:"resolvedIp,omitempty"`   // 系统解析得到的 IP
+	ResolveError   string  `json:"resolveError,omitempty"` // 系统解析失败的原因
+	CertValid      *bool   `json:"certValid,omitempty"`    // 证书是否对域名有效，tls/http 探测时检查
+	CertError      string  `json:"certError,omitempty"`
+	LatencyMs      float64 `json:"latencyMs,omitempty"`
+	HTTPStatus     int     `json:"httpStatus,omitempty"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// ConnectionReport test 命令的输出
+type ConnectionReport struct {
+	reportHeader
+	Probe   string             `json:"probe"`
+	P50Ms   float64            `json:"p50Ms"` // 通过测试的域名的延迟中位数
+	P95Ms   float64            `json:"p95Ms"`
+	Total   int                `json:"total"`
+	Passed  int                `json:"passed"`
+	Failed  int                `json:"failed"`
+	Results []DomainTestResult `json:"results"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// SystemReport 系统信息
+type SystemReport struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	HostsFile string `json:"hostsFile"`
+}
+
+// DiagnosticsReport diagnose 命令的输出
+type DiagnosticsReport struct {
+	reportHeader
+	System      SystemReport      `json:"system"`
+	Directories []DirReport       `json:"directories"`
+	Config      *ConfigReport     `json:"config,omitempty"`
+	ConfigError string            `json:"configError,omitempty"`
+	Connection  *ConnectionReport `json:"connection"`
+}
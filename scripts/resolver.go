@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 解析模式
+const (
+	resolverModeWorker = "worker" // 从 worker 数据源获取（默认）
+	resolverModeDoH    = "doh"    // 客户端直接通过 DoH 解析
+	resolverModeAuto   = "auto"   // 优先使用数据源，全部失败时回退到 DoH
+)
+
+// DoH 响应格式
+const (
+	dohFormatJSON = "json" // application/dns-json
+	dohFormatWire = "wire" // RFC 8484 application/dns-message
+)
+
+const (
+	// defaultDoHTimeout DoH 服务未设置超时时的默认值
+	defaultDoHTimeout = 5 * time.Second
+	// dohConcurrency 同时解析的域名数量
+	dohConcurrency = 8
+	// dohSourceName 使用 DoH 解析时记录的数据源名称
+	dohSourceName = "doh"
+)
+
+// ResolverConfig 解析方式配置
+type ResolverConfig struct {
+	Mode      string        `json:"mode"`
+	Providers []DoHProvider `json:"providers,omitempty"`
+}
+
+// DoHProvider DNS-over-HTTPS 服务
+type DoHProvider struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Format  string `json:"format"`            // json 或 wire
+	Timeout int    `json:"timeout,omitempty"` // 超时（秒），0 表示使用默认值
+}
+
+// defaultDoHProviders 默认的 DoH 服务，与 worker 使用的 DNS_PROVIDERS 一致
+var defaultDoHProviders = []DoHProvider{
+	{Name: "Cloudflare DNS", URL: "https://1.1.1.1/dns-query", Format: dohFormatJSON},
+	{Name: "Google DNS", URL: "https://dns.google/resolve", Format: dohFormatJSON},
+}
+
+// resolverMode 返回配置的解析模式
+func (c *Config) resolverMode() string {
+	if c == nil || c.Resolver == nil || c.Resolver.Mode == "" {
+		return resolverModeWorker
+	}
+	return c.Resolver.Mode
+}
+
+// dohProviders 返回配置的 DoH 服务，未配置时使用默认值
+func (c *Config) dohProviders() []DoHProvider {
+	if c == nil || c.Resolver == nil || len(c.Resolver.Providers) == 0 {
+		return defaultDoHProviders
+	}
+	return c.Resolver.Providers
+}
+
+// hostsDomains 返回 DoH 模式下需要解析的域名，未配置时使用默认列表
+func (c *Config) hostsDomains() []string {
+	if c == nil || len(c.Domains) == 0 {
+		return defaultDomains
+	}
+	return c.Domains
+}
+
+// validateResolverMode 检查解析模式是否受支持
+func validateResolverMode(mode string) error {
+	switch mode {
+	case resolverModeWorker, resolverModeDoH, resolverModeAuto:
+		return nil
+	default:
+		return fmt.Errorf("无效的解析模式: %s（可选 worker, doh, auto）", mode)
+	}
+}
+
+// setResolverMode 修改解析模式
+func (app *App) setResolverMode(mode string) error {
+	if err := validateResolverMode(mode); err != nil {
+		return err
+	}
+
+	err := app.modifyConfig(func(config *Config) error {
+		if config.Resolver == nil {
+			config.Resolver = &ResolverConfig{}
+		}
+		config.Resolver.Mode = mode
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	app.logWithLevel(SUCCESS, "解析模式已修改为 %s", mode)
+	return nil
+}
+
+// timeout 返回 DoH 服务的请求超时
+func (p DoHProvider) timeout() time.Duration {
+	if p.Timeout <= 0 {
+		return defaultDoHTimeout
+	}
+	return time.Duration(p.Timeout) * time.Second
+}
+
+// resolve 通过 DoH 服务查询域名的 A 记录
+func (p DoHProvider) resolve(ctx context.Context, client *http.Client, domain string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	switch p.Format {
+	case dohFormatWire:
+		return p.resolveWire(ctx, client, domain)
+	case dohFormatJSON, "":
+		return p.resolveJSON(ctx, client, domain)
+	default:
+		return nil, fmt.Errorf("不支持的 DoH 格式: %s", p.Format)
+	}
+}
+
+// dohJSONResponse application/dns-json 响应
+type dohJSONResponse struct {
+	Status int `json:"Status"`
+	Answer []struct {
+		Type int    `json:"type"`
+		Data string `json:"data"`
+	} `json:"Answer"`
+}
+
+// resolveJSON 使用 JSON 格式查询
+func (p DoHProvider) resolveJSON(ctx context.Context, client *http.Client, domain string) ([]string, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("name", domain)
+	query.Set("type", "A")
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+
+	body, err := doDoHRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp dohJSONResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("解析 DoH 响应失败: %w", err)
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("DNS 查询失败，状态码: %d", resp.Status)
+	}
+
+	var ips []string
+	for _, answer := range resp.Answer {
+		// 只取 A 记录
+		if answer.Type != 1 {
+			continue
+		}
+		if ip := net.ParseIP(answer.Data); ip != nil && ip.To4() != nil {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips, nil
+}
+
+// resolveWire 使用 RFC 8484 二进制格式查询
+func (p DoHProvider) resolveWire(ctx context.Context, client *http.Client, domain string) ([]string, error) {
+	query, err := buildDNSQuery(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	body, err := doDoHRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+	return parseDNSResponse(body)
+}
+
+// doDoHRequest 发送 DoH 请求并返回响应内容
+func doDoHRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH 服务返回状态码: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+}
+
+// buildDNSQuery 构造查询 A 记录的 DNS 报文，按 RFC 8484 建议 ID 置 0
+func buildDNSQuery(domain string) ([]byte, error) {
+	var buf bytes.Buffer
+	// 报文头：ID=0，RD=1，QDCOUNT=1
+	buf.Write([]byte{0, 0, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, fmt.Errorf("无效的域名: %s", domain)
+		}
+		buf.WriteByte(byte(len(label)))
+		buf.WriteString(label)
+	}
+	buf.WriteByte(0)
+	// QTYPE=A，QCLASS=IN
+	buf.Write([]byte{0, 1, 0, 1})
+	return buf.Bytes(), nil
+}
+
+// errShortDNSMessage 表示 DNS 报文被截断
+var errShortDNSMessage = errors.New("DNS 响应报文不完整")
+
+// parseDNSResponse 解析 DNS 响应报文，返回回答部分中的 A 记录
+func parseDNSResponse(msg []byte) ([]string, error) {
+	if len(msg) < 12 {
+		return nil, errShortDNSMessage
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		return nil, fmt.Errorf("DNS 查询失败，状态码: %d", rcode)
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:6]))
+	ancount := int(binary.BigEndian.Uint16(msg[6:8]))
+
+	offset := 12
+	for i := 0; i < qdcount; i++ {
+		next, err := skipDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		offset = next + 4 // QTYPE + QCLASS
+	}
+
+	var ips []string
+	for i := 0; i < ancount; i++ {
+		next, err := skipDNSName(msg, offset)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errShortDNSMessage
+		}
+		rtype := binary.BigEndian.Uint16(msg[next : next+2])
+		rdlength := int(binary.BigEndian.Uint16(msg[next+8 : next+10]))
+		rdata := next + 10
+		if rdata+rdlength > len(msg) {
+			return nil, errShortDNSMessage
+		}
+		if rtype == 1 && rdlength == 4 {
+			ips = append(ips, net.IP(msg[rdata:rdata+4]).String())
+		}
+		offset = rdata + rdlength
+	}
+	return ips, nil
+}
+
+// skipDNSName 跳过报文中的域名（支持压缩指针），返回其后的偏移量
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, errShortDNSMessage
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			// 压缩指针占两个字节，指针之后名称结束
+			if offset+2 > len(msg) {
+				return 0, errShortDNSMessage
+			}
+			return offset + 2, nil
+		default:
+			offset += 1 + length
+		}
+	}
+}
+
+// domainResolution 单个域名的解析结果
+type domainResolution struct {
+	Domain string
+	IPs    []string // 所有候选 IP，按 DoH 服务顺序去重
+}
+
+// resolveDomains 并发地通过所有 DoH 服务解析域名
+// 每个域名同时查询全部服务，候选 IP 按服务顺序合并去重；没有任何结果的域名会被跳过
+func (app *App) resolveDomains(ctx context.Context, providers []DoHProvider, domains []string) []domainResolution {
+	client := app.dohClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	results := make([]domainResolution, len(domains))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, dohConcurrency)
+	for i, domain := range domains {
+		wg.Add(1)
+		go func(i int, domain string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			answers := make([][]string, len(providers))
+			var pwg sync.WaitGroup
+			for j, provider := range providers {
+				pwg.Add(1)
+				go func(j int, provider DoHProvider) {
+					defer pwg.Done()
+					ips, err := provider.resolve(ctx, client, domain)
+					if err != nil {
+						app.logWithLevel(WARNING, "%s 解析 %s 失败: %v", provider.Name, domain, err)
+						return
+					}
+					answers[j] = ips
+				}(j, provider)
+			}
+			pwg.Wait()
+
+			seen := make(map[string]bool)
+			result := domainResolution{Domain: domain}
+			for _, ips := range answers {
+				for _, ip := range ips {
+					if !seen[ip] {
+						seen[ip] = true
+						result.IPs = append(result.IPs, ip)
+					}
+				}
+			}
+			results[i] = result
+		}(i, domain)
+	}
+	wg.Wait()
+
+	resolved := results[:0]
+	for _, result := range results {
+		if len(result.IPs) == 0 {
+			app.logWithLevel(WARNING, "未能解析域名: %s", result.Domain)
+			continue
+		}
+		resolved = append(resolved, result)
+	}
+	return resolved
+}
+
+// resolveHosts 使用 DoH 解析配置的域名列表，生成与数据源相同的 hosts 记录
+// 一个域名有多个候选 IP 时全部返回
+func (app *App) resolveHosts(config *Config) ([]HostEntry, error) {
+	providers := config.dohProviders()
+	domains := config.hostsDomains()
+	app.logWithLevel(INFO, "正在通过 %d 个 DoH 服务解析 %d 个域名", len(providers), len(domains))
+
+	resolutions := app.resolveDomains(context.Background(), providers, domains)
+
+	allowed := make(map[string]bool, len(domains))
+	for _, domain := range domains {
+		allowed[strings.ToLower(domain)] = true
+	}
+	var entries []HostEntry
+	domainCount := 0
+	for _, r := range resolutions {
+		// 保留全部候选 IP，由 selectBestEntries 为每个域名选出最优的一个
+		valid := 0
+		for _, ip := range r.IPs {
+			entry, err := validateEntry(ip, r.Domain, allowed)
+			if err != nil {
+				app.logWithLevel(WARNING, "忽略 %s 的解析结果: %v", r.Domain, err)
+				continue
+			}
+			entries = append(entries, entry)
+			valid++
+		}
+		if valid > 0 {
+			domainCount++
+		}
+	}
+
+	if domainCount < minHostEntries {
+		return nil, fmt.Errorf("成功解析的域名过少: %d（至少需要 %d 个）", domainCount, minHostEntries)
+	}
+	return entries, nil
+}
+
+// hostsEntries 按配置的解析模式获取 hosts 记录，返回记录和写入管理区块的数据源名称
+func (app *App) hostsEntries(config *Config) ([]HostEntry, string, error) {
+	mode := config.resolverMode()
+	if mode == resolverModeDoH {
+		entries, err := app.resolveHosts(config)
+		if err != nil {
+			return nil, "", fmt.Errorf("DoH 解析失败: %w", err)
+		}
+		return entries, dohSourceName, nil
+	}
+
+	entries, source, err := app.fetchHosts(config.hostsSources())
+	if err == nil {
+		return entries, source.URL, nil
+	}
+	if mode != resolverModeAuto {
+		return nil, "", err
+	}
+
+	app.logWithLevel(WARNING, "%v，回退为客户端 DoH 解析", err)
+	entries, dohErr := app.resolveHosts(config)
+	if dohErr != nil {
+		return nil, "", fmt.Errorf("%v; DoH 解析失败: %w", err, dohErr)
+	}
+	return entries, dohSourceName, nil
+}
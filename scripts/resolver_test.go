@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// dohAnswers 本地 DoH 服务的应答：域名 → A 记录，nil 表示 NXDOMAIN
+type dohAnswers map[string][]string
+
+// newJSONDoHServer 启动返回 application/dns-json 的本地 DoH 服务
+func newJSONDoHServer(t *testing.T, answers dohAnswers) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("type") != "A" {
+			http.Error(w, "unexpected type", http.StatusBadRequest)
+			return
+		}
+		ips, ok := answers[r.URL.Query().Get("name")]
+		resp := map[string]interface{}{"Status": 0}
+		if !ok || ips == nil {
+			resp["Status"] = 3
+		} else {
+			// CNAME 记录应被忽略
+			records := []map[string]interface{}{{"type": 5, "data": "alias.example."}}
+			for _, ip := range ips {
+				records = append(records, map[string]interface{}{"type": 1, "data": ip})
+			}
+			resp["Answer"] = records
+		}
+		w.Header().Set("Content-Type", "application/dns-json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newWireDoHServer 启动返回 RFC 8484 application/dns-message 的本地 DoH 服务
+func newWireDoHServer(t *testing.T, answers dohAnswers) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		query, err := io.ReadAll(r.Body)
+		if err != nil || len(query) < 12 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(buildTestDNSResponse(query, answers))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// buildTestDNSResponse 根据查询报文构造响应报文，回答部分使用指向问题的压缩指针
+func buildTestDNSResponse(query []byte, answers dohAnswers) []byte {
+	var labels []string
+	for offset := 12; query[offset] != 0; offset += int(query[offset]) + 1 {
+		labels = append(labels, string(query[offset+1:offset+1+int(query[offset])]))
+	}
+	ips, ok := answers[strings.Join(labels, ".")]
+
+	resp := append([]byte{}, query...)
+	resp[2] |= 0x80 // QR
+	if !ok || ips == nil {
+		resp[3] = 0x03 // NXDOMAIN
+		return resp
+	}
+	binary.BigEndian.PutUint16(resp[6:8], uint16(len(ips)))
+	for _, ip := range ips {
+		// NAME=指针，TYPE=A，CLASS=IN，TTL=60，RDLENGTH=4
+		resp = append(resp, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+		resp = append(resp, net.ParseIP(ip).To4()...)
+	}
+	return resp
+}
+
+func newTestResolverApp(t *testing.T, client *http.Client) *App {
+	t.Helper()
+	app := newAppWithBaseDir(t.TempDir())
+	app.dohClient = client
+	return app
+}
+
+func TestResolveDomains(t *testing.T) {
+	answers := dohAnswers{
+		"github.com":     {"140.82.112.3", "140.82.112.4"},
+		"api.github.com": {"140.82.112.5"},
+		"empty.example":  {},
+		"nx.example":     nil,
+	}
+	domains := []string{"github.com", "nx.example", "api.github.com", "empty.example"}
+	want := []domainResolution{
+		{Domain: "github.com", IPs: []string{"140.82.112.3", "140.82.112.4"}},
+		{Domain: "api.github.com", IPs: []string{"140.82.112.5"}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		server func(*testing.T, dohAnswers) *httptest.Server
+	}{
+		{"json", dohFormatJSON, newJSONDoHServer},
+		{"wire", dohFormatWire, newWireDoHServer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tt.server(t, answers)
+			app := newTestResolverApp(t, server.Client())
+			providers := []DoHProvider{{Name: "local", URL: server.URL + "/dns-query", Format: tt.format}}
+
+			got := app.resolveDomains(context.Background(), providers, domains)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("resolveDomains() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveDomainsProviderFallback(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(failing.Close)
+	primary := newJSONDoHServer(t, dohAnswers{"github.com": {"140.82.112.3"}})
+	secondary := newWireDoHServer(t, dohAnswers{"github.com": {"140.82.112.4", "140.82.112.3"}, "api.github.com": {"140.82.112.5"}})
+
+	app := newTestResolverApp(t, &http.Client{})
+	providers := []DoHProvider{
+		{Name: "failing", URL: failing.URL, Format: dohFormatJSON},
+		{Name: "primary", URL: primary.URL, Format: dohFormatJSON},
+		{Name: "secondary", URL: secondary.URL, Format: dohFormatWire},
+	}
+
+	// 失败的服务被跳过，其他服务的结果按服务顺序合并去重
+	got := app.resolveDomains(context.Background(), providers, []string{"github.com", "api.github.com"})
+	want := []domainResolution{
+		{Domain: "github.com", IPs: []string{"140.82.112.3", "140.82.112.4"}},
+		{Domain: "api.github.com", IPs: []string{"140.82.112.5"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestResolveDomainsAllProvidersFail(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	t.Cleanup(failing.Close)
+
+	app := newTestResolverApp(t, failing.Client())
+	providers := []DoHProvider{
+		{Name: "json", URL: failing.URL, Format: dohFormatJSON},
+		{Name: "wire", URL: failing.URL, Format: dohFormatWire},
+	}
+	if got := app.resolveDomains(context.Background(), providers, []string{"github.com"}); len(got) != 0 {
+		t.Errorf("resolveDomains() = %v, want no results", got)
+	}
+}
+
+func TestParseDNSResponse(t *testing.T) {
+	query, err := buildDNSQuery("github.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := buildTestDNSResponse(query, dohAnswers{"github.com": {"140.82.112.3"}})
+
+	ips, err := parseDNSResponse(resp)
+	if err != nil || !reflect.DeepEqual(ips, []string{"140.82.112.3"}) {
+		t.Errorf("parseDNSResponse() = %v, %v", ips, err)
+	}
+
+	// 截断的报文
+	if _, err := parseDNSResponse(resp[:len(resp)-2]); !errors.Is(err, errShortDNSMessage) {
+		t.Errorf("parseDNSResponse(truncated) error = %v, want %v", err, errShortDNSMessage)
+	}
+
+	// NXDOMAIN
+	nx := buildTestDNSResponse(query, dohAnswers{})
+	if _, err := parseDNSResponse(nx); err == nil {
+		t.Error("parseDNSResponse(NXDOMAIN) error = nil")
+	}
+}
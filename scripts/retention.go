@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// 默认的备份保留策略
+const (
+	defaultKeepLast       = 20
+	defaultKeepDailyDays  = 7
+	defaultKeepWeeklyDays = 30
+	defaultMaxBackupMB    = 50
+)
+
+// RetentionConfig 备份保留策略
+// 满足任一保留规则的备份都会保留，之后若总大小仍超过上限，从最旧的开始删除（最新的备份始终保留）
+type RetentionConfig struct {
+	KeepLast       int `json:"keepLast"`       // 保留最新的 N 个备份
+	KeepDailyDays  int `json:"keepDailyDays"`  // 最近 X 天内每天保留最新的一个
+	KeepWeeklyDays int `json:"keepWeeklyDays"` // 最近 X 天内每周保留最新的一个
+	MaxTotalMB     int `json:"maxTotalMB"`     // 备份总大小上限（MB），0 表示不限制
+}
+
+// retention 返回备份保留策略，未配置时使用默认值
+func (c *Config) retention() RetentionConfig {
+	if c == nil || c.Retention == nil {
+		return RetentionConfig{
+			KeepLast:       defaultKeepLast,
+			KeepDailyDays:  defaultKeepDailyDays,
+			KeepWeeklyDays: defaultKeepWeeklyDays,
+			MaxTotalMB:     defaultMaxBackupMB,
+		}
+	}
+	return *c.Retention
+}
+
+// validate 检查保留策略
+func (r RetentionConfig) validate() error {
+	if r.KeepLast < 1 {
+		return fmt.Errorf("keepLast 至少为 1")
+	}
+	if r.KeepDailyDays < 0 || r.KeepWeeklyDays < 0 || r.MaxTotalMB < 0 {
+		return fmt.Errorf("保留天数和大小上限不能为负数")
+	}
+	return nil
+}
+
+// field 返回保留策略中指定字段的指针，key 为 JSON 字段名
+func (r *RetentionConfig) field(key string) *int {
+	switch key {
+	case "keepLast":
+		return &r.KeepLast
+	case "keepDailyDays":
+		return &r.KeepDailyDays
+	case "keepWeeklyDays":
+		return &r.KeepWeeklyDays
+	case "maxTotalMB":
+		return &r.MaxTotalMB
+	default:
+		return nil
+	}
+}
+
+// setRetention 修改保留策略中的一项，未配置的其他项使用默认值
+func (app *App) setRetention(key string, value int) error {
+	err := app.modifyConfig(func(config *Config) error {
+		policy := config.retention()
+		field := policy.field(key)
+		if field == nil {
+			return fmt.Errorf("未知的保留策略项: %s", key)
+		}
+		*field = value
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		config.Retention = &policy
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.logWithLevel(SUCCESS, "备份保留策略 %s 已修改为 %d", key, value)
+	return nil
+}
+
+// selectBackupsToPrune 按保留策略返回需要删除的备份，backups 须按时间从新到旧排列
+func selectBackupsToPrune(backups []backupInfo, policy RetentionConfig, now time.Time) []backupInfo {
+	keep := make([]bool, len(backups))
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	for i, b := range backups {
+		if i < policy.KeepLast {
+			keep[i] = true
+		}
+		age := now.Sub(b.time)
+
+		day := b.time.Format("20060102")
+		if age < time.Duration(policy.KeepDailyDays)*24*time.Hour && !days[day] {
+			days[day] = true
+			keep[i] = true
+		}
+
+		year, week := b.time.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if age < time.Duration(policy.KeepWeeklyDays)*24*time.Hour && !weeks[weekKey] {
+			weeks[weekKey] = true
+			keep[i] = true
+		}
+	}
+
+	// 超过大小上限时，从最旧的保留项开始删除，最新的备份始终保留
+	if policy.MaxTotalMB > 0 {
+		limit := int64(policy.MaxTotalMB) * 1024 * 1024
+		var total int64
+		for i, b := range backups {
+			if keep[i] {
+				total += b.size
+			}
+		}
+		for i := len(backups) - 1; i > 0 && total > limit; i-- {
+			if keep[i] {
+				keep[i] = false
+				total -= backups[i].size
+			}
+		}
+	}
+
+	var prune []backupInfo
+	for i, b := range backups {
+		if !keep[i] {
+			prune = append(prune, b)
+		}
+	}
+	return prune
+}
+
+// pruneBackups 按配置的保留策略清理备份，dryRun 为 true 时只列出将被删除的备份
+func (app *App) pruneBackups(dryRun bool) ([]backupInfo, error) {
+	config, err := app.loadConfigOrDefault()
+	if err != nil {
+		return nil, err
+	}
+	policy := config.retention()
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("备份保留策略无效: %w", err)
+	}
+
+	backups, err := app.backupInfos()
+	if err != nil {
+		return nil, err
+	}
+
+	prune := selectBackupsToPrune(backups, policy, time.Now())
+	if dryRun {
+		return prune, nil
+	}
+
+	var removed []backupInfo
+	for _, b := range prune {
+		if err := app.deleteBackup(b.name); err != nil {
+			app.logWithLevel(WARNING, "删除备份 %s 失败: %v", b.name, err)
+			continue
+		}
+		removed = append(removed, b)
+	}
+	return removed, nil
+}
+
+// runBackupPrune 执行备份清理并输出结果
+func (app *App) runBackupPrune(dryRun bool) error {
+	prune, err := app.pruneBackups(dryRun)
+	if err != nil {
+		return fmt.Errorf("清理备份失败: %w", err)
+	}
+
+	if len(prune) == 0 {
+		app.logWithLevel(INFO, "没有需要清理的备份")
+		return nil
+	}
+
+	var total int64
+	for _, b := range prune {
+		total += b.size
+	}
+	if dryRun {
+		fmt.Printf("\n以下 %d 个备份将被删除（共 %.2f KB）：\n", len(prune), float64(total)/1024)
+		for _, b := range prune {
+			fmt.Printf("  %s\t%.2f KB\n", b.name, float64(b.size)/1024)
+		}
+		return nil
+	}
+	app.logWithLevel(SUCCESS, "已清理 %d 个备份，释放 %.2f KB", len(prune), float64(total)/1024)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectBackupsToPrune(t *testing.T) {
+	// 2024-03-15 是星期五，所在的 ISO 周为 3 月 11 日到 17 日
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)
+	const mb = 1024 * 1024
+
+	// backups 按给出的顺序（从新到旧）生成备份，ages 为距 now 的时长，名称为 b0、b1…
+	backups := func(size int64, ages ...time.Duration) []backupInfo {
+		list := make([]backupInfo, len(ages))
+		for i, age := range ages {
+			list[i] = backupInfo{name: fmt.Sprintf("b%d", i), time: now.Add(-age), size: size}
+		}
+		return list
+	}
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		backups []backupInfo
+		policy  RetentionConfig
+		want    []string
+	}{
+		{
+			name:    "没有备份",
+			backups: nil,
+			policy:  RetentionConfig{KeepLast: 1},
+			want:    nil,
+		},
+		{
+			name:    "只保留最新的 N 个",
+			backups: backups(1, time.Hour, 2*time.Hour, 3*time.Hour, 4*time.Hour, 5*time.Hour),
+			policy:  RetentionConfig{KeepLast: 2},
+			want:    []string{"b2", "b3", "b4"},
+		},
+		{
+			name:    "每天保留最新的一个",
+			backups: backups(1, time.Hour, 2*time.Hour, 26*time.Hour, 50*time.Hour, 10*day),
+			policy:  RetentionConfig{KeepLast: 1, KeepDailyDays: 3},
+			want:    []string{"b1", "b4"},
+		},
+		{
+			name:    "每周保留最新的一个",
+			backups: backups(1, time.Hour, 2*day, 8*day, 9*day, 40*day),
+			policy:  RetentionConfig{KeepLast: 1, KeepWeeklyDays: 30},
+			want:    []string{"b1", "b3", "b4"},
+		},
+		{
+			name:    "满足任一规则即保留",
+			backups: backups(1, time.Hour, 2*time.Hour, 2*day, 3*day, 9*day, 40*day),
+			policy:  RetentionConfig{KeepLast: 2, KeepDailyDays: 7, KeepWeeklyDays: 30},
+			want:    []string{"b5"},
+		},
+		{
+			name:    "超过大小上限时从最旧的开始删除",
+			backups: backups(mb, time.Hour, 2*time.Hour, 3*time.Hour, 4*time.Hour),
+			policy:  RetentionConfig{KeepLast: 10, MaxTotalMB: 2},
+			want:    []string{"b2", "b3"},
+		},
+		{
+			name:    "最新的备份超过上限时仍保留",
+			backups: backups(5*mb, time.Hour, 2*time.Hour),
+			policy:  RetentionConfig{KeepLast: 10, MaxTotalMB: 1},
+			want:    []string{"b1"},
+		},
+		{
+			name:    "大小上限为 0 表示不限制",
+			backups: backups(100*mb, time.Hour, 2*time.Hour, 3*time.Hour),
+			policy:  RetentionConfig{KeepLast: 3},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, b := range selectBackupsToPrune(tt.backups, tt.policy, now) {
+				got = append(got, b.name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectBackupsToPrune() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetentionValidate(t *testing.T) {
+	tests := []struct {
+		policy RetentionConfig
+		ok     bool
+	}{
+		{RetentionConfig{KeepLast: 1}, true},
+		{(*Config)(nil).retention(), true},
+		{RetentionConfig{KeepLast: 0}, false},
+		{RetentionConfig{KeepLast: 1, KeepDailyDays: -1}, false},
+		{RetentionConfig{KeepLast: 1, MaxTotalMB: -1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v.validate() error = %v, want ok %v", tt.policy, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 更新计划类型
+const (
+	scheduleInterval = "interval" // 每隔固定分钟数
+	scheduleDaily    = "daily"    // 每天固定时间
+	scheduleCron     = "cron"     // cron 表达式
+)
+
+const (
+	// defaultUpdateInterval 默认的更新间隔（分钟）
+	defaultUpdateInterval = 60
+	// minUpdateInterval、maxUpdateInterval 更新间隔的范围（分钟）
+	minUpdateInterval = 5
+	maxUpdateInterval = 24 * 60
+	// maxJitter 随机延迟的上限（分钟）
+	maxJitter = 12 * 60
+)
+
+// ScheduleConfig 自动更新计划
+type ScheduleConfig struct {
+	Kind     string `json:"kind"`               // interval, daily 或 cron
+	Interval int    `json:"interval,omitempty"` // 更新间隔（分钟），kind 为 interval 时有效
+	At       string `json:"at,omitempty"`       // 每天的更新时间 HH:MM，kind 为 daily 时有效
+	Cron     string `json:"cron,omitempty"`     // 五段式 cron 表达式，kind 为 cron 时有效
+	Jitter   int    `json:"jitter,omitempty"`   // 每次更新前的最大随机延迟（分钟），避免大量机器同时访问数据源
+}
+
+// schedule 返回更新计划；旧版本配置只有 updateInterval，按固定间隔处理
+func (c *Config) schedule() ScheduleConfig {
+	if c != nil && c.Schedule != nil {
+		return *c.Schedule
+	}
+	interval := defaultUpdateInterval
+	if c != nil && c.UpdateInterval > 0 {
+		interval = c.UpdateInterval
+	}
+	return ScheduleConfig{Kind: scheduleInterval, Interval: interval}
+}
+
+// intervalSchedule 返回固定间隔的更新计划
+func intervalSchedule(minutes int) ScheduleConfig {
+	return ScheduleConfig{Kind: scheduleInterval, Interval: minutes}
+}
+
+// String 返回更新计划的文字描述
+func (s ScheduleConfig) String() string {
+	var desc string
+	switch s.Kind {
+	case scheduleInterval:
+		if s.Interval%60 == 0 {
+			desc = fmt.Sprintf("每 %d 小时", s.Interval/60)
+		} else {
+			desc = fmt.Sprintf("每 %d 分钟", s.Interval)
+		}
+	case scheduleDaily:
+		desc = "每天 " + s.At
+	case scheduleCron:
+		desc = "cron: " + s.Cron
+	default:
+		desc = "未知的更新计划"
+	}
+	if s.Jitter > 0 {
+		desc += fmt.Sprintf("（随机延迟 0-%d 分钟）", s.Jitter)
+	}
+	return desc
+}
+
+// validate 检查更新计划
+func (s ScheduleConfig) validate() error {
+	switch s.Kind {
+	case scheduleInterval:
+		if err := validateInterval(s.Interval); err != nil {
+			return err
+		}
+	case scheduleDaily:
+		if _, _, err := parseTimeOfDay(s.At); err != nil {
+			return err
+		}
+	case scheduleCron:
+		if _, err := parseCronExpr(s.Cron); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("无效的更新计划类型: %s（可选 interval, daily, cron）", s.Kind)
+	}
+	if s.Jitter < 0 || s.Jitter > maxJitter {
+		return fmt.Errorf("随机延迟必须在 0 到 %d 分钟之间", maxJitter)
+	}
+	// 延迟达到间隔时相邻两次更新可能重叠，并争用 hosts 锁
+	if s.Kind == scheduleInterval && s.Jitter > 0 && s.Jitter >= s.Interval {
+		return fmt.Errorf("随机延迟（%d 分钟）必须小于更新间隔（%d 分钟）", s.Jitter, s.Interval)
+	}
+	return nil
+}
+
+// validateInterval 检查更新间隔是否在允许范围内
+func validateInterval(interval int) error {
+	if interval < minUpdateInterval || interval > maxUpdateInterval {
+		return fmt.Errorf("无效的更新间隔: %d 分钟（应在 %d 到 %d 分钟之间）", interval, minUpdateInterval, maxUpdateInterval)
+	}
+	return nil
+}
+
+// parseIntervalMinutes 解析更新间隔，支持纯数字（分钟）以及 90m、2h、1h30m 等写法
+func parseIntervalMinutes(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if minutes, err := strconv.Atoi(s); err == nil {
+		return minutes, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d%time.Minute != 0 {
+		return 0, fmt.Errorf("无效的更新间隔: %s（例如 45、90m、2h）", s)
+	}
+	return int(d / time.Minute), nil
+}
+
+// parseTimeOfDay 解析 HH:MM 格式的时间
+func parseTimeOfDay(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的时间: %s（格式为 HH:MM）", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// parseSchedule 解析更新计划的文字写法：
+// 间隔（45、90m、2h）、每天的时间（03:30）或 cron 表达式（cron:0 */3 * * *，或直接写五段）
+func parseSchedule(spec string) (ScheduleConfig, error) {
+	spec = strings.TrimSpace(spec)
+	var s ScheduleConfig
+	switch {
+	case strings.HasPrefix(spec, "cron:"):
+		s = ScheduleConfig{Kind: scheduleCron, Cron: strings.TrimSpace(strings.TrimPrefix(spec, "cron:"))}
+	case len(strings.Fields(spec)) == 5:
+		s = ScheduleConfig{Kind: scheduleCron, Cron: strings.Join(strings.Fields(spec), " ")}
+	case strings.Contains(spec, ":"):
+		s = ScheduleConfig{Kind: scheduleDaily, At: spec}
+	default:
+		minutes, err := parseIntervalMinutes(spec)
+		if err != nil {
+			return s, err
+		}
+		s = intervalSchedule(minutes)
+	}
+	if err := s.validate(); err != nil {
+		return s, err
+	}
+	if s.Kind == scheduleDaily {
+		// 统一为两位数的格式
+		hour, minute, _ := parseTimeOfDay(s.At)
+		s.At = fmt.Sprintf("%02d:%02d", hour, minute)
+	}
+	return s, nil
+}
+
+// cron 表达式各字段的取值范围：分、时、日、月、星期
+var cronFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// cronField cron 表达式中的一个字段
+type cronField struct {
+	values []int // 升序排列的取值
+	any    bool  // 字段为 *，不限制
+}
+
+// cronExpr 解析后的 cron 表达式
+type cronExpr struct {
+	minute, hour, day, month, weekday cronField
+}
+
+// parseCronExpr 解析五段式 cron 表达式，支持 *、数字、范围（a-b）、步长（*/n、a-b/n）和逗号列表
+func parseCronExpr(expr string) (*cronExpr, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("无效的 cron 表达式: %q（需要分、时、日、月、星期五段）", expr)
+	}
+
+	var fields [5]cronField
+	for i, part := range parts {
+		lo, hi := cronFieldBounds[i][0], cronFieldBounds[i][1]
+		if i == 4 {
+			hi = 7 // 星期允许用 7 表示周日
+		}
+		field, err := parseCronField(part, lo, hi)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 cron 表达式 %q: %w", expr, err)
+		}
+		fields[i] = field
+	}
+
+	// 星期中的 7 与 0 相同
+	if !fields[4].any {
+		set := make(map[int]bool)
+		for _, v := range fields[4].values {
+			set[v%7] = true
+		}
+		fields[4].values = sortedKeys(set)
+	}
+	e := &cronExpr{fields[0], fields[1], fields[2], fields[3], fields[4]}
+	if !e.possible() {
+		return nil, fmt.Errorf("cron 表达式 %q 永远不会执行：所选月份中没有这些日期", expr)
+	}
+	return e, nil
+}
+
+// cronMonthDays 每个月最多的天数，2 月按闰年计
+var cronMonthDays = [13]int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// possible 判断表达式是否存在执行时间
+// 只有日期受限制而星期不受限制时，才可能出现所有日期都不在所选月份中的情况，例如 0 0 31 2 *
+func (e *cronExpr) possible() bool {
+	if e.day.any || !e.weekday.any {
+		return true
+	}
+	for _, m := range e.month.values {
+		if e.day.values[0] <= cronMonthDays[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCronField 解析 cron 表达式中的一个字段
+func parseCronField(s string, lo, hi int) (cronField, error) {
+	if s == "*" {
+		return cronField{values: rangeInts(lo, hi, 1), any: true}, nil
+	}
+
+	set := make(map[int]bool)
+	for _, item := range strings.Split(s, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return cronField{}, fmt.Errorf("无效的步长: %s", item)
+			}
+			step = n
+		}
+
+		start, end := lo, hi
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err1, err2 error
+			start, err1 = strconv.Atoi(a)
+			end, err2 = strconv.Atoi(b)
+			if err1 != nil || err2 != nil || start > end {
+				return cronField{}, fmt.Errorf("无效的范围: %s", item)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return cronField{}, fmt.Errorf("无效的取值: %s", item)
+			}
+			start, end = n, n
+			if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi {
+			return cronField{}, fmt.Errorf("取值超出范围 %d-%d: %s", lo, hi, item)
+		}
+		for v := start; v <= end; v += step {
+			set[v] = true
+		}
+	}
+	return cronField{values: sortedKeys(set)}, nil
+}
+
+// rangeInts 返回 [lo, hi] 内以 step 为步长的整数
+func rangeInts(lo, hi, step int) []int {
+	var values []int
+	for v := lo; v <= hi; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+// sortedKeys 返回集合中升序排列的整数
+func sortedKeys(set map[int]bool) []int {
+	values := make([]int, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+	return values
+}
+
+// step 如果字段取值是从 lo 开始、步长固定且覆盖到范围末尾的序列，返回步长，否则返回 0
+func (f cronField) step(lo, hi int) int {
+	if len(f.values) < 2 || f.values[0] != lo {
+		return 0
+	}
+	step := f.values[1] - f.values[0]
+	for i := 1; i < len(f.values); i++ {
+		if f.values[i]-f.values[i-1] != step {
+			return 0
+		}
+	}
+	if f.values[len(f.values)-1]+step <= hi {
+		return 0
+	}
+	return step
+}
+
+// cronList 将取值写成 cron 字段：全部取值写成 *，等步长序列写成 */n，其他写成逗号列表
+func cronList(values []int, lo, hi int) string {
+	field := cronField{values: values}
+	if len(values) == hi-lo+1 {
+		return "*"
+	}
+	if step := field.step(lo, hi); step > 0 {
+		return fmt.Sprintf("*/%d", step)
+	}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// cronLines 将更新计划转换为 cron 的时间字段，每个元素对应 cron.d 中的一行
+// 间隔不能整除 60 分钟时，从每天 0 点开始按间隔列出当天的执行时间，执行小时相同的分钟合并为一行；
+// 间隔不能整除一天时，跨过 0 点的那次间隔会变短，cron 和 systemd timer 无法按固定间隔执行，直接报错
+func (s ScheduleConfig) cronLines() ([]string, error) {
+	switch s.Kind {
+	case scheduleDaily:
+		hour, minute, _ := parseTimeOfDay(s.At)
+		return []string{fmt.Sprintf("%d %d * * *", minute, hour)}, nil
+	case scheduleCron:
+		return []string{s.Cron}, nil
+	}
+
+	if (24*60)%s.Interval != 0 {
+		lower, upper := nearestDayDivisors(s.Interval)
+		return nil, fmt.Errorf("更新间隔 %d 分钟不能整除 24 小时，cron 和 systemd timer 无法按固定间隔执行，请改用 %d 或 %d 分钟等能整除 24 小时的间隔，或使用 daemon 命令", s.Interval, lower, upper)
+	}
+	if s.Interval < 60 && 60%s.Interval == 0 {
+		return []string{fmt.Sprintf("*/%d * * * *", s.Interval)}, nil
+	}
+	hours := make(map[int][]int) // 分钟 → 该分钟执行的小时
+	for t := 0; t < 24*60; t += s.Interval {
+		hours[t%60] = append(hours[t%60], t/60)
+	}
+	var order []string
+	minutes := make(map[string][]int) // 小时字段 → 在这些小时执行的分钟
+	for m := 0; m < 60; m++ {
+		if len(hours[m]) == 0 {
+			continue
+		}
+		key := cronList(hours[m], 0, 23)
+		if _, ok := minutes[key]; !ok {
+			order = append(order, key)
+		}
+		minutes[key] = append(minutes[key], m)
+	}
+
+	lines := make([]string, 0, len(order))
+	for _, key := range order {
+		lines = append(lines, fmt.Sprintf("%s %s * * *", cronList(minutes[key], 0, 59), key))
+	}
+	return lines, nil
+}
+
+// nearestDayDivisors 返回允许范围内与 interval 最接近、能整除 24 小时的较小和较大间隔
+func nearestDayDivisors(interval int) (lower, upper int) {
+	lower, upper = minUpdateInterval, maxUpdateInterval
+	for n := interval - 1; n >= minUpdateInterval; n-- {
+		if (24*60)%n == 0 {
+			lower = n
+			break
+		}
+	}
+	for n := interval + 1; n <= maxUpdateInterval; n++ {
+		if (24*60)%n == 0 {
+			upper = n
+			break
+		}
+	}
+	return lower, upper
+}
+
+// maxCalendarIntervals launchd StartCalendarInterval 条目数量上限
+const maxCalendarIntervals = 500
+
+// calendarIntervals 将 cron 表达式展开为 launchd 的 StartCalendarInterval 条目
+// 每个条目只包含受限制的字段；日和星期同时受限制时按 cron 的规则取并集，分别生成条目
+func (e *cronExpr) calendarIntervals() ([]map[string]int, error) {
+	type key struct {
+		name  string
+		field cronField
+	}
+	base := []key{{"Minute", e.minute}, {"Hour", e.hour}, {"Month", e.month}}
+
+	var variants [][]key
+	switch {
+	case !e.day.any && !e.weekday.any:
+		variants = [][]key{append(base, key{"Day", e.day}), append(base, key{"Weekday", e.weekday})}
+	case !e.day.any:
+		variants = [][]key{append(base, key{"Day", e.day})}
+	case !e.weekday.any:
+		variants = [][]key{append(base, key{"Weekday", e.weekday})}
+	default:
+		variants = [][]key{base}
+	}
+
+	var result []map[string]int
+	for _, keys := range variants {
+		entries := []map[string]int{{}}
+		for _, k := range keys {
+			if k.field.any {
+				continue
+			}
+			var next []map[string]int
+			for _, entry := range entries {
+				for _, v := range k.field.values {
+					item := make(map[string]int, len(entry)+1)
+					for name, value := range entry {
+						item[name] = value
+					}
+					item[k.name] = v
+					next = append(next, item)
+				}
+			}
+			entries = next
+			if len(result)+len(entries) > maxCalendarIntervals {
+				return nil, fmt.Errorf("cron 表达式展开后超过 %d 个 launchd 时间点，请使用更简单的表达式", maxCalendarIntervals)
+			}
+		}
+		result = append(result, entries...)
+	}
+	return result, nil
+}
+
+// schtasksWeekdays schtasks /d 参数使用的星期名称
+var schtasksWeekdays = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+// schtasksArgs 将更新计划转换为 schtasks /create 的触发器参数
+// schtasks 只能表示部分 cron 表达式：每 n 分钟、每 n 小时的固定分钟、每天/每周/每月的固定时间
+func (s ScheduleConfig) schtasksArgs() ([]string, error) {
+	switch s.Kind {
+	case scheduleInterval:
+		switch {
+		case s.Interval == 24*60:
+			return []string{"/sc", "daily", "/st", "00:00"}, nil
+		case s.Interval%60 == 0:
+			return []string{"/sc", "hourly", "/mo", strconv.Itoa(s.Interval / 60)}, nil
+		default:
+			return []string{"/sc", "minute", "/mo", strconv.Itoa(s.Interval)}, nil
+		}
+	case scheduleDaily:
+		return []string{"/sc", "daily", "/st", s.At}, nil
+	}
+
+	e, err := parseCronExpr(s.Cron)
+	if err != nil {
+		return nil, err
+	}
+	unsupported := fmt.Errorf("Windows 计划任务无法表示 cron 表达式 %q，请改用固定间隔或每天固定时间", s.Cron)
+	if !e.month.any {
+		return nil, unsupported
+	}
+
+	// 每 n 分钟
+	if e.hour.any && e.day.any && e.weekday.any {
+		if e.minute.any {
+			return []string{"/sc", "minute", "/mo", "1"}, nil
+		}
+		if step := e.minute.step(0, 59); step > 0 && 60%step == 0 {
+			return []string{"/sc", "minute", "/mo", strconv.Itoa(step)}, nil
+		}
+	}
+	if len(e.minute.values) != 1 {
+		return nil, unsupported
+	}
+	minute := e.minute.values[0]
+
+	// 每 n 小时的固定分钟
+	if e.day.any && e.weekday.any && len(e.hour.values) > 1 {
+		step := 1
+		if !e.hour.any {
+			step = e.hour.step(0, 23)
+		}
+		if step == 0 || 24%step != 0 {
+			return nil, unsupported
+		}
+		return []string{"/sc", "hourly", "/mo", strconv.Itoa(step), "/st", fmt.Sprintf("00:%02d", minute)}, nil
+	}
+	if len(e.hour.values) != 1 {
+		return nil, unsupported
+	}
+	at := fmt.Sprintf("%02d:%02d", e.hour.values[0], minute)
+
+	// 每天、每周或每月的固定时间
+	switch {
+	case e.day.any && e.weekday.any:
+		return []string{"/sc", "daily", "/st", at}, nil
+	case e.day.any:
+		days := make([]string, len(e.weekday.values))
+		for i, d := range e.weekday.values {
+			days[i] = schtasksWeekdays[d]
+		}
+		return []string{"/sc", "weekly", "/d", strings.Join(days, ","), "/st", at}, nil
+	case e.weekday.any:
+		days := make([]string, len(e.day.values))
+		for i, d := range e.day.values {
+			days[i] = strconv.Itoa(d)
+		}
+		return []string{"/sc", "monthly", "/d", strings.Join(days, ","), "/st", at}, nil
+	default:
+		return nil, unsupported
+	}
+}
+
+// cronSearchYears 查找 cron 表达式下一次执行时间的范围；2 月 29 日最长相隔 8 年（如 2096 到 2104 年）
+const cronSearchYears = 8
+
+// next 返回 after 之后下一次按计划执行的时间，不含随机延迟
+func (s ScheduleConfig) next(after time.Time) (time.Time, error) {
+	switch s.Kind {
+	case scheduleInterval:
+		return after.Add(time.Duration(s.Interval) * time.Minute), nil
+	case scheduleDaily:
+		hour, minute, _ := parseTimeOfDay(s.At)
+		t := time.Date(after.Year(), after.Month(), after.Day(), hour, minute, 0, 0, after.Location())
+		if !t.After(after) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	expr, err := parseCronExpr(s.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// 先按天查找日期符合的日子，再在当天查找第一个晚于 after 的时间
+	start := after.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	end := day.AddDate(cronSearchYears, 0, 0)
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !expr.matchesDay(day) {
+			continue
+		}
+		for _, hour := range expr.hour.values {
+			for _, minute := range expr.minute.values {
+				t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+				if !t.Before(start) {
+					return t, nil
+				}
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("cron 表达式 %q 在 %d 年内没有执行时间", s.Cron, cronSearchYears)
+}
+
+// matchesDay 判断日期是否符合 cron 表达式的月、日和星期；日和星期都受限制时满足其一即可
+func (e *cronExpr) matchesDay(t time.Time) bool {
+	if !e.month.has(int(t.Month())) {
+		return false
+	}
+	day, weekday := e.day.has(t.Day()), e.weekday.has(int(t.Weekday()))
+	if !e.day.any && !e.weekday.any {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// has 判断字段是否包含 v
+func (f cronField) has(v int) bool {
+	if f.any {
+		return true
+	}
+	i := sort.SearchInts(f.values, v)
+	return i < len(f.values) && f.values[i] == v
+}
+
+// sleepJitter 在定时更新前随机等待 0 到 maxMinutes 分钟
+func (app *App) sleepJitter(maxMinutes int) {
+	if maxMinutes <= 0 {
+		return
+	}
+	delay := time.Duration(rand.Int63n(int64(maxMinutes) * int64(time.Minute)))
+	app.logWithLevel(INFO, "随机延迟 %s 后开始更新", delay.Round(time.Second))
+	time.Sleep(delay)
+}
+
+// readLine 从标准输入读取一行非空内容，用于输入含空格的 cron 表达式
+// 逐字节读取，避免缓冲吞掉后续输入
+func readLine() string {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n == 0 || err != nil {
+			return strings.TrimSpace(string(line))
+		}
+		if buf[0] == '\n' {
+			if text := strings.TrimSpace(string(line)); text != "" {
+				return text
+			}
+			line = line[:0]
+			continue
+		}
+		line = append(line, buf[0])
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule ScheduleConfig
+		want     []string
+		wantErr  bool
+	}{
+		{"整除 60 的间隔", intervalSchedule(15), []string{"*/15 * * * *"}, false},
+		{"整除一天的分钟间隔", intervalSchedule(45), []string{
+			"*/45 */3 * * *",
+			"15 2,5,8,11,14,17,20,23 * * *",
+			"30 1,4,7,10,13,16,19,22 * * *",
+		}, false},
+		{"90 分钟", intervalSchedule(90), []string{"0 */3 * * *", "30 1,4,7,10,13,16,19,22 * * *"}, false},
+		{"按小时", intervalSchedule(120), []string{"0 */2 * * *"}, false},
+		{"24 小时", intervalSchedule(24 * 60), []string{"0 0 * * *"}, false},
+		{"100 分钟不能整除一天", intervalSchedule(100), nil, true},
+		{"7 分钟不能整除一天", intervalSchedule(7), nil, true},
+		{"每天固定时间", ScheduleConfig{Kind: scheduleDaily, At: "04:30"}, []string{"30 4 * * *"}, false},
+		{"cron 表达式原样使用", ScheduleConfig{Kind: scheduleCron, Cron: "0 */3 * * 1-5"}, []string{"0 */3 * * 1-5"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.cronLines()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cronLines() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cronLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronLinesCoverWholeDay(t *testing.T) {
+	// 能整除一天的间隔展开后，每天的执行时间应从 0 点开始、间隔完全相同
+	for interval := minUpdateInterval; interval <= maxUpdateInterval; interval++ {
+		if (24*60)%interval != 0 {
+			continue
+		}
+		lines, err := intervalSchedule(interval).cronLines()
+		if err != nil {
+			t.Fatalf("cronLines(%d) error = %v", interval, err)
+		}
+		var minutes []int
+		day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		for m := 0; m < 24*60; m++ {
+			at := day.Add(time.Duration(m) * time.Minute)
+			for _, line := range lines {
+				expr, err := parseCronExpr(line)
+				if err != nil {
+					t.Fatalf("parseCronExpr(%q) error = %v", line, err)
+				}
+				if expr.minute.has(at.Minute()) && expr.hour.has(at.Hour()) {
+					minutes = append(minutes, m)
+					break
+				}
+			}
+		}
+		if len(minutes) != 24*60/interval || minutes[0] != 0 {
+			t.Fatalf("interval %d: %d runs starting at %d", interval, len(minutes), minutes[0])
+		}
+		for i := 1; i < len(minutes); i++ {
+			if minutes[i]-minutes[i-1] != interval {
+				t.Fatalf("interval %d: gap %d at minute %d", interval, minutes[i]-minutes[i-1], minutes[i])
+			}
+		}
+	}
+}
+
+func TestNearestDayDivisors(t *testing.T) {
+	tests := map[int][2]int{
+		7:    {6, 8},
+		100:  {96, 120},
+		1000: {720, 1440},
+	}
+	for interval, want := range tests {
+		lower, upper := nearestDayDivisors(interval)
+		if lower != want[0] || upper != want[1] {
+			t.Errorf("nearestDayDivisors(%d) = %d, %d, want %d, %d", interval, lower, upper, want[0], want[1])
+		}
+	}
+}
+
+func TestSchtasksArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule ScheduleConfig
+		want     []string
+		wantErr  bool
+	}{
+		{"分钟间隔", intervalSchedule(15), []string{"/sc", "minute", "/mo", "15"}, false},
+		{"不能整除一天的间隔", intervalSchedule(100), []string{"/sc", "minute", "/mo", "100"}, false},
+		{"小时间隔", intervalSchedule(120), []string{"/sc", "hourly", "/mo", "2"}, false},
+		{"24 小时", intervalSchedule(24 * 60), []string{"/sc", "daily", "/st", "00:00"}, false},
+		{"每天固定时间", ScheduleConfig{Kind: scheduleDaily, At: "04:30"}, []string{"/sc", "daily", "/st", "04:30"}, false},
+		{"cron 每 n 分钟", ScheduleConfig{Kind: scheduleCron, Cron: "*/15 * * * *"}, []string{"/sc", "minute", "/mo", "15"}, false},
+		{"cron 每分钟", ScheduleConfig{Kind: scheduleCron, Cron: "* * * * *"}, []string{"/sc", "minute", "/mo", "1"}, false},
+		{"cron 每 n 小时", ScheduleConfig{Kind: scheduleCron, Cron: "30 */2 * * *"}, []string{"/sc", "hourly", "/mo", "2", "/st", "00:30"}, false},
+		{"cron 每天", ScheduleConfig{Kind: scheduleCron, Cron: "0 4 * * *"}, []string{"/sc", "daily", "/st", "04:00"}, false},
+		{"cron 每周", ScheduleConfig{Kind: scheduleCron, Cron: "0 4 * * 1,3"}, []string{"/sc", "weekly", "/d", "MON,WED", "/st", "04:00"}, false},
+		{"cron 每月", ScheduleConfig{Kind: scheduleCron, Cron: "0 4 1,15 * *"}, []string{"/sc", "monthly", "/d", "1,15", "/st", "04:00"}, false},
+		{"cron 限制月份", ScheduleConfig{Kind: scheduleCron, Cron: "0 4 * 6 *"}, nil, true},
+		{"cron 同时限制日期和星期", ScheduleConfig{Kind: scheduleCron, Cron: "0 4 1 * 1"}, nil, true},
+		{"cron 多个分钟", ScheduleConfig{Kind: scheduleCron, Cron: "7,37 * * * *"}, nil, true},
+		{"cron 工作日每 3 小时", ScheduleConfig{Kind: scheduleCron, Cron: "0 */3 * * 1-5"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.schtasksArgs()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("schtasksArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schtasksArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCronExprImpossible(t *testing.T) {
+	tests := map[string]bool{
+		"0 0 31 2 *":    false,
+		"0 0 30,31 2 *": false,
+		"0 0 31 4,6 *":  false,
+		"0 0 31 2,3 *":  true,
+		"0 0 29 2 *":    true,
+		"0 0 31 2 1":    true, // 日期和星期满足其一即可
+		"0 0 * 2 *":     true,
+	}
+	for expr, ok := range tests {
+		if _, err := parseCronExpr(expr); (err == nil) != ok {
+			t.Errorf("parseCronExpr(%q) error = %v, want ok %v", expr, err, ok)
+		}
+	}
+	if _, err := parseSchedule("0 0 31 2 *"); err == nil {
+		t.Error("parseSchedule(0 0 31 2 *) error = nil")
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		schedule ScheduleConfig
+		after    string
+		want     string
+	}{
+		{intervalSchedule(90), "2024-01-01 10:00", "2024-01-01 11:30"},
+		{ScheduleConfig{Kind: scheduleDaily, At: "04:30"}, "2024-01-01 03:00", "2024-01-01 04:30"},
+		{ScheduleConfig{Kind: scheduleDaily, At: "04:30"}, "2024-01-01 04:30", "2024-01-02 04:30"},
+		{ScheduleConfig{Kind: scheduleCron, Cron: "*/15 * * * *"}, "2024-01-01 10:07", "2024-01-01 10:15"},
+		{ScheduleConfig{Kind: scheduleCron, Cron: "0 */3 * * 1-5"}, "2024-01-05 22:00", "2024-01-08 00:00"},
+		{ScheduleConfig{Kind: scheduleCron, Cron: "15 4 1 * 1"}, "2024-01-02 00:00", "2024-01-08 04:15"},
+		{ScheduleConfig{Kind: scheduleCron, Cron: "0 0 29 2 *"}, "2097-03-01 00:00", "2104-02-29 00:00"},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.schedule.next(at(tt.after))
+		if err != nil {
+			t.Errorf("%s.next(%s) error = %v", tt.schedule, tt.after, err)
+			continue
+		}
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%s.next(%s) = %s, want %s", tt.schedule, tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+
+	if _, err := (ScheduleConfig{Kind: scheduleCron, Cron: "0 0 31 2 *"}).next(at("2024-01-01 00:00")); err == nil {
+		t.Error("next() of impossible cron expression error = nil")
+	}
+}
+
+func TestLaunchdTrigger(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule ScheduleConfig
+		want     string
+	}{
+		{
+			name:     "固定间隔",
+			schedule: intervalSchedule(100),
+			want:     "<key>StartInterval</key><integer>6000</integer>",
+		},
+		{
+			name:     "每天固定时间",
+			schedule: ScheduleConfig{Kind: scheduleDaily, At: "04:30"},
+			want: "<key>StartCalendarInterval</key><array>" +
+				"<dict><key>Hour</key><integer>4</integer><key>Minute</key><integer>30</integer></dict>" +
+				"</array>",
+		},
+		{
+			name:     "cron 限制月份",
+			schedule: ScheduleConfig{Kind: scheduleCron, Cron: "0 4 * 6 *"},
+			want: "<key>StartCalendarInterval</key><array>" +
+				"<dict><key>Month</key><integer>6</integer><key>Hour</key><integer>4</integer><key>Minute</key><integer>0</integer></dict>" +
+				"</array>",
+		},
+		{
+			name:     "cron 同时限制日期和星期",
+			schedule: ScheduleConfig{Kind: scheduleCron, Cron: "0 4 1 * 1"},
+			want: "<key>StartCalendarInterval</key><array>" +
+				"<dict><key>Day</key><integer>1</integer><key>Hour</key><integer>4</integer><key>Minute</key><integer>0</integer></dict>" +
+				"<dict><key>Weekday</key><integer>1</integer><key>Hour</key><integer>4</integer><key>Minute</key><integer>0</integer></dict>" +
+				"</array>",
+		},
+		{
+			name:     "cron 多个分钟",
+			schedule: ScheduleConfig{Kind: scheduleCron, Cron: "7,37 * * * *"},
+			want: "<key>StartCalendarInterval</key><array>" +
+				"<dict><key>Minute</key><integer>7</integer></dict>" +
+				"<dict><key>Minute</key><integer>37</integer></dict>" +
+				"</array>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := launchdTrigger(tt.schedule)
+			if err != nil {
+				t.Fatalf("launchdTrigger() error = %v", err)
+			}
+			if got := strings.Join(strings.Fields(got), ""); got != tt.want {
+				t.Errorf("launchdTrigger() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// 展开后条目过多时报错
+	if _, err := launchdTrigger(ScheduleConfig{Kind: scheduleCron, Cron: "*/2 0-20 * * *"}); err == nil {
+		t.Error("launchdTrigger(*/2 0-20 * * *) error = nil")
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// 定时任务后端
+const (
+	schedulerAuto     = "auto"     // 自动检测（默认）
+	schedulerSystemd  = "systemd"  // systemd .service + .timer
+	schedulerCronD    = "cron"     // /etc/cron.d 下的文件，适用于 cron/cronie
+	schedulerCrontab  = "crontab"  // root 用户的 crontab
+	schedulerLaunchd  = "launchd"  // macOS LaunchDaemon
+	schedulerSchtasks = "schtasks" // Windows 计划任务
+)
+
+// scheduledJob 定时任务要执行的内容
+// 程序自己写入日志文件，定时任务不再重定向输出到单独的日志
+type scheduledJob struct {
+	schedule ScheduleConfig
+	exe      string   // 程序路径
+	args     []string // 程序参数，不含随机延迟参数
+}
+
+// command 返回完整的命令参数；后端不支持原生随机延迟时，由程序自身的 --jitter 实现
+func (j scheduledJob) command(nativeJitter bool) []string {
+	args := append([]string{j.exe}, j.args...)
+	if j.schedule.Jitter > 0 && !nativeJitter {
+		args = append(args, "--jitter", fmt.Sprint(j.schedule.Jitter))
+	}
+	return args
+}
+
+// scheduler 定时任务后端
+type scheduler interface {
+	// name 返回后端名称
+	name() string
+	// available 判断当前系统是否可以使用该后端
+	available() bool
+	// install 安装或替换定时任务
+	install(job scheduledJob) error
+	// remove 移除定时任务，任务不存在时不报错
+	remove() error
+	// installed 判断定时任务是否已安装，detail 为任务所在的位置
+	installed() (ok bool, detail string)
+}
+
+// platformSchedulers 返回当前操作系统支持的后端，按自动检测的优先顺序排列
+func platformSchedulers() []scheduler {
+	switch runtime.GOOS {
+	case "darwin":
+		return []scheduler{launchdScheduler{}}
+	case "windows":
+		return []scheduler{schtasksScheduler{}}
+	case "linux":
+		return []scheduler{
+			systemdScheduler{root: "/"},
+			cronDScheduler{path: linuxCronPath},
+			crontabScheduler{},
+		}
+	default:
+		return nil
+	}
+}
+
+// validateSchedulerName 检查定时任务后端名称
+func validateSchedulerName(name string) error {
+	if name == schedulerAuto {
+		return nil
+	}
+	for _, s := range platformSchedulers() {
+		if s.name() == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("当前系统不支持定时任务后端: %s", name)
+}
+
+// schedulerName 返回配置的定时任务后端
+func (c *Config) schedulerName() string {
+	if c == nil || c.Scheduler == "" {
+		return schedulerAuto
+	}
+	return c.Scheduler
+}
+
+// selectScheduler 按配置选择定时任务后端，auto 时返回第一个可用的后端
+func selectScheduler(name string) (scheduler, error) {
+	for _, s := range platformSchedulers() {
+		if name != schedulerAuto && s.name() != name {
+			continue
+		}
+		if !s.available() {
+			if name != schedulerAuto {
+				return nil, fmt.Errorf("定时任务后端 %s 在当前系统不可用", name)
+			}
+			continue
+		}
+		return s, nil
+	}
+	if name != schedulerAuto {
+		return nil, fmt.Errorf("当前系统不支持定时任务后端: %s", name)
+	}
+	return nil, fmt.Errorf("未找到可用的定时任务系统（%s）", runtime.GOOS)
+}
+
+// activeScheduler 返回已安装定时任务的后端，没有安装时返回 nil
+func activeScheduler() (scheduler, string) {
+	for _, s := range platformSchedulers() {
+		if ok, detail := s.installed(); ok {
+			return s, detail
+		}
+	}
+	return nil, ""
+}
+
+// setupCron 设置定时任务
+// 定时任务直接调用本程序的 update 命令，与手动更新共用同一套更新流程
+func (app *App) setupCron(schedule ScheduleConfig) error {
+	if err := schedule.validate(); err != nil {
+		return err
+	}
+
+	config, err := app.loadConfigOrDefault()
+	if err != nil {
+		return err
+	}
+	backend, err := selectScheduler(config.schedulerName())
+	if err != nil {
+		return err
+	}
+
+	exe, err := app.installBinary()
+	if err != nil {
+		return fmt.Errorf("安装程序文件失败: %w", err)
+	}
+
+	// 清理旧版本生成的更新脚本
+	os.Remove(filepath.Join(app.baseDir, "update.sh"))
+	os.Remove(filepath.Join(app.baseDir, "update.bat"))
+
+	job := scheduledJob{
+		schedule: schedule,
+		exe:      exe,
+		args:     app.scheduledUpdateArgs(),
+	}
+	if err := backend.install(job); err != nil {
+		return err
+	}
+
+	// 切换后端时移除其他后端中的旧任务，避免重复执行
+	for _, s := range platformSchedulers() {
+		if s.name() == backend.name() {
+			continue
+		}
+		if ok, _ := s.installed(); ok {
+			if err := s.remove(); err != nil {
+				app.logWithLevel(WARNING, "移除 %s 中的旧定时任务失败: %v", s.name(), err)
+			}
+		}
+	}
+
+	app.logWithLevel(INFO, "定时任务已通过 %s 设置", backend.name())
+	return nil
+}
+
+// scheduledUpdateArgs 返回定时任务调用本程序时使用的参数
+func (app *App) scheduledUpdateArgs() []string {
+	return []string{"--base-dir", app.baseDir, "update", "--unattended"}
+}
+
+// removeCron 移除所有后端中的定时任务
+func (app *App) removeCron() {
+	for _, s := range platformSchedulers() {
+		if ok, _ := s.installed(); !ok {
+			continue
+		}
+		if err := s.remove(); err != nil {
+			app.logWithLevel(WARNING, "移除 %s 定时任务失败: %v", s.name(), err)
+		}
+	}
+}
+
+// setScheduler 修改定时任务后端，自动更新开启时重新设置定时任务
+func (app *App) setScheduler(name string) error {
+	if err := validateSchedulerName(name); err != nil {
+		return err
+	}
+
+	var previous string
+	var saved Config
+	err := app.modifyConfig(func(config *Config) error {
+		previous = config.Scheduler
+		config.Scheduler = name
+		if name == schedulerAuto {
+			config.Scheduler = ""
+		}
+		saved = *config
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if saved.AutoUpdate {
+		if err := app.setupCron(saved.schedule()); err != nil {
+			// 恢复原来的后端设置
+			app.modifyConfig(func(config *Config) error {
+				config.Scheduler = previous
+				return nil
+			})
+			return fmt.Errorf("更新定时任务失败: %w", err)
+		}
+	}
+	app.logWithLevel(SUCCESS, "定时任务后端已修改为 %s", name)
+	return nil
+}
+
+// commandExists 判断命令是否在 PATH 中
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
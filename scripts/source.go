@@ -0,0 +1,387 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultSourceTimeout 数据源未设置超时时的默认请求超时
+	defaultSourceTimeout = 15 * time.Second
+	// sourceRetries 每个数据源的最大尝试次数
+	sourceRetries = 3
+	// sourceRetryDelay 首次重试前的等待时间，之后每次翻倍
+	sourceRetryDelay = time.Second
+)
+
+// hostsSources 返回按顺序尝试的数据源，未配置时使用默认 API
+func (c *Config) hostsSources() []Source {
+	if c == nil || len(c.Sources) == 0 {
+		return []Source{{URL: hostsAPI}}
+	}
+	return c.Sources
+}
+
+// redactedValue 显示配置时代替认证令牌和请求头取值的文本
+const redactedValue = "***"
+
+// hasCredentials 判断是否有数据源带有认证令牌或请求头
+func (c *Config) hasCredentials() bool {
+	for _, s := range c.Sources {
+		if s.Token != "" || len(s.Headers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted 返回隐藏了数据源认证令牌和请求头取值的配置副本，用于显示
+func (c *Config) redacted() *Config {
+	copied := *c
+	copied.Sources = make([]Source, len(c.Sources))
+	for i, s := range c.Sources {
+		if s.Token != "" {
+			s.Token = redactedValue
+		}
+		if len(s.Headers) > 0 {
+			headers := make(map[string]string, len(s.Headers))
+			for key := range s.Headers {
+				headers[key] = redactedValue
+			}
+			s.Headers = headers
+		}
+		copied.Sources[i] = s
+	}
+	return &copied
+}
+
+// timeout 返回数据源的请求超时
+func (s Source) timeout() time.Duration {
+	if s.Timeout <= 0 {
+		return defaultSourceTimeout
+	}
+	return time.Duration(s.Timeout) * time.Second
+}
+
+// jsonURL 返回数据源对应的 JSON 接口地址
+// worker 的 /hosts 对应 /hosts.json；地址本身以 .json 结尾时直接使用；其他地址不提供 JSON
+func (s Source) jsonURL() string {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasSuffix(u.Path, ".json"):
+		return s.URL
+	case strings.HasSuffix(u.Path, "/hosts"):
+		u.Path += ".json"
+		return u.String()
+	default:
+		return ""
+	}
+}
+
+// get 请求数据源地址并返回响应内容
+func (s Source) get(rawURL string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := &http.Client{Timeout: s.timeout()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download hosts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxHostsPayloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if len(content) > maxHostsPayloadSize {
+		return nil, errPayloadTooLarge
+	}
+	return content, nil
+}
+
+// statusError 表示服务器返回了非 200 状态码
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned status code: %d", e.code)
+}
+
+// errPayloadTooLarge 表示响应超过 maxHostsPayloadSize
+var errPayloadTooLarge = errors.New("hosts 数据过大")
+
+// errInvalidPayload 表示数据源返回的数据未通过校验，重试同一数据源没有意义
+var errInvalidPayload = errors.New("hosts 数据校验失败")
+
+// fetch 从数据源获取一次并校验 hosts 数据
+// 优先请求 JSON 接口，不可用时回退为解析文本格式的 /hosts
+func (s Source) fetch(allowed map[string]bool) ([]HostEntry, string, error) {
+	if jsonURL := s.jsonURL(); jsonURL != "" {
+		content, err := s.get(jsonURL)
+		if err == nil {
+			entries, err := parseHostsJSON(content, allowed)
+			if err == nil {
+				return entries, "json", nil
+			}
+			if jsonURL == s.URL {
+				return nil, "json", fmt.Errorf("%w: %v", errInvalidPayload, err)
+			}
+		} else if jsonURL == s.URL {
+			return nil, "json", err
+		}
+	}
+
+	content, err := s.get(s.URL)
+	if err != nil {
+		return nil, "text", err
+	}
+	entries, err := parseHostsPayload(content, allowed)
+	if err != nil {
+		return nil, "text", fmt.Errorf("%w: %v", errInvalidPayload, err)
+	}
+	return entries, "text", nil
+}
+
+// fetchHosts 按顺序从各数据源获取并校验 hosts 数据
+// 每个数据源在网络错误时按指数退避重试；数据校验失败时直接尝试下一个数据源
+func (app *App) fetchHosts(sources []Source) ([]HostEntry, Source, error) {
+	allowed := app.allowedDomains()
+
+	var lastErr error
+	for i, source := range sources {
+		app.logWithLevel(INFO, "正在从数据源 %d/%d 获取 hosts 数据: %s", i+1, len(sources), source.URL)
+
+		delay := sourceRetryDelay
+		for attempt := 1; attempt <= sourceRetries; attempt++ {
+			entries, format, err := source.fetch(allowed)
+			if err == nil {
+				app.logWithLevel(DEBUG, "数据格式: %s", format)
+				return entries, source, nil
+			}
+
+			lastErr = err
+			if errors.Is(err, errInvalidPayload) || errors.Is(err, errPayloadTooLarge) || attempt == sourceRetries {
+				app.logWithLevel(WARNING, "数据源 %s 获取失败: %v", source.URL, err)
+				break
+			}
+			app.logWithLevel(WARNING, "数据源 %s 第 %d 次请求失败: %v，%s 后重试", source.URL, attempt, err, delay)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	return nil, Source{}, fmt.Errorf("所有数据源均不可用: %w", lastErr)
+}
+
+// recordSource 在配置中记录最近一次成功的数据源和更新时间
+func (app *App) recordSource(sourceURL string) {
+	err := app.modifyConfig(func(config *Config) error {
+		config.LastSource = sourceURL
+		config.LastUpdate = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		app.logWithLevel(WARNING, "记录数据源失败: %v", err)
+	}
+}
+
+// validateSourceURL 检查数据源地址是否为 http(s) URL
+func validateSourceURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("无效的数据源地址: %s", raw)
+	}
+	return nil
+}
+
+// addSource 添加数据源，position 从 1 开始，0 表示追加到末尾
+func (app *App) addSource(source Source, position int) error {
+	if err := validateSourceURL(source.URL); err != nil {
+		return err
+	}
+
+	err := app.modifyConfig(func(config *Config) error {
+		sources := config.hostsSources()
+		for _, s := range sources {
+			if s.URL == source.URL {
+				return fmt.Errorf("数据源已存在: %s", source.URL)
+			}
+		}
+		if position <= 0 || position > len(sources) {
+			position = len(sources) + 1
+		}
+		config.Sources = append(append(append([]Source{}, sources[:position-1]...), source), sources[position-1:]...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.logWithLevel(SUCCESS, "已添加数据源 #%d: %s", position, source.URL)
+	return nil
+}
+
+// removeSource 删除数据源，ref 可以是序号或 URL
+func (app *App) removeSource(ref string) error {
+	var removed Source
+	err := app.modifyConfig(func(config *Config) error {
+		sources := config.hostsSources()
+		index, err := findSource(sources, ref)
+		if err != nil {
+			return err
+		}
+		if len(sources) == 1 {
+			return fmt.Errorf("至少需要保留一个数据源")
+		}
+		removed = sources[index]
+		config.Sources = append(append([]Source{}, sources[:index]...), sources[index+1:]...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.logWithLevel(SUCCESS, "已删除数据源: %s", removed.URL)
+	return nil
+}
+
+// moveSource 调整数据源顺序，to 为新的序号（从 1 开始）
+func (app *App) moveSource(ref string, to int) error {
+	var source Source
+	err := app.modifyConfig(func(config *Config) error {
+		sources := append([]Source{}, config.hostsSources()...)
+		index, err := findSource(sources, ref)
+		if err != nil {
+			return err
+		}
+		if to < 1 || to > len(sources) {
+			return fmt.Errorf("无效的目标位置: %d", to)
+		}
+		source = sources[index]
+		sources = append(sources[:index], sources[index+1:]...)
+		config.Sources = append(sources[:to-1], append([]Source{source}, sources[to-1:]...)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	app.logWithLevel(SUCCESS, "数据源 %s 已移动到第 %d 位", source.URL, to)
+	return nil
+}
+
+// findSource 根据序号或 URL 查找数据源下标
+func findSource(sources []Source, ref string) (int, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(sources) {
+			return 0, fmt.Errorf("无效的数据源序号: %d", n)
+		}
+		return n - 1, nil
+	}
+	for i, s := range sources {
+		if s.URL == ref {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("数据源不存在: %s", ref)
+}
+
+// listSources 显示数据源列表
+func (app *App) listSources() error {
+	config, err := app.loadConfig()
+	if err != nil {
+		return fmt.Errorf("读取配置失败: %w", err)
+	}
+
+	fmt.Println("\n数据源（按顺序尝试）：")
+	for i, s := range config.hostsSources() {
+		var extras []string
+		if s.Token != "" {
+			extras = append(extras, "认证令牌")
+		}
+		if len(s.Headers) > 0 {
+			extras = append(extras, fmt.Sprintf("%d 个请求头", len(s.Headers)))
+		}
+		extras = append(extras, fmt.Sprintf("超时 %s", s.timeout()))
+		mark := ""
+		if s.URL == config.LastSource {
+			mark = " (最近成功)"
+		}
+		fmt.Printf("%d. %s%s [%s]\n", i+1, s.URL, mark, strings.Join(extras, ", "))
+	}
+	return nil
+}
+
+// manageSourcesMenu 数据源管理菜单
+func (app *App) manageSourcesMenu() error {
+	for {
+		if err := app.listSources(); err != nil {
+			return err
+		}
+
+		fmt.Println("\n1. 添加数据源")
+		fmt.Println("2. 删除数据源")
+		fmt.Println("3. 调整顺序")
+		fmt.Println("0. 返回")
+		fmt.Print("请输入选项: ")
+
+		var choice int
+		fmt.Scanf("%d", &choice)
+
+		var err error
+		switch choice {
+		case 1:
+			var source Source
+			fmt.Print("请输入数据源地址: ")
+			fmt.Scanf("%s", &source.URL)
+			fmt.Print("请输入认证令牌（留空跳过）: ")
+			fmt.Scanf("%s", &source.Token)
+			fmt.Print("请输入超时秒数（0 使用默认值）: ")
+			fmt.Scanf("%d", &source.Timeout)
+			fmt.Print("请输入插入位置（0 追加到末尾）: ")
+			var position int
+			fmt.Scanf("%d", &position)
+			err = app.addSource(source, position)
+		case 2:
+			fmt.Print("请输入要删除的数据源序号: ")
+			var ref string
+			fmt.Scanf("%s", &ref)
+			err = app.removeSource(ref)
+		case 3:
+			var ref string
+			var to int
+			fmt.Print("请输入要移动的数据源序号: ")
+			fmt.Scanf("%s", &ref)
+			fmt.Print("请输入新的位置: ")
+			fmt.Scanf("%d", &to)
+			err = app.moveSource(ref, to)
+		case 0:
+			return nil
+		default:
+			fmt.Println("无效的选项，请重试")
+		}
+		if err != nil {
+			app.logWithLevel(ERROR, "%v", err)
+		}
+	}
+}
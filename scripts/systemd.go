@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// systemdUnitName systemd 单元名称（不含后缀）
+const systemdUnitName = "github-hosts"
+
+// systemdUnitDir 相对于根目录的单元文件目录
+const systemdUnitDir = "etc/systemd/system"
+
+// systemdScheduler 使用 systemd .service + .timer 的定时任务
+// root 为文件系统根目录，正常为 /，生成单元文件时可指向临时目录
+type systemdScheduler struct {
+	root string
+}
+
+func (s systemdScheduler) name() string { return schedulerSystemd }
+
+// available 以 /run/systemd/system 判断系统是否由 systemd 启动
+func (s systemdScheduler) available() bool {
+	if _, err := os.Stat(filepath.Join(s.root, "run/systemd/system")); err != nil {
+		return false
+	}
+	return commandExists("systemctl")
+}
+
+// unitPath 返回单元文件路径，suffix 为 service 或 timer
+func (s systemdScheduler) unitPath(suffix string) string {
+	return filepath.Join(s.root, systemdUnitDir, systemdUnitName+"."+suffix)
+}
+
+func (s systemdScheduler) install(job scheduledJob) error {
+	if err := s.writeUnits(job); err != nil {
+		return err
+	}
+	if output, err := exec.Command("systemctl", "daemon-reload").CombinedOutput(); err != nil {
+		return fmt.Errorf("重新加载 systemd 配置失败: %s, %v", string(output), err)
+	}
+	// 计划变化后需要重启 timer 才会按新计划计算下次执行时间
+	if output, err := exec.Command("systemctl", "enable", systemdUnitName+".timer").CombinedOutput(); err != nil {
+		return fmt.Errorf("启用 systemd timer 失败: %s, %v", string(output), err)
+	}
+	if output, err := exec.Command("systemctl", "restart", systemdUnitName+".timer").CombinedOutput(); err != nil {
+		return fmt.Errorf("启动 systemd timer 失败: %s, %v", string(output), err)
+	}
+	return nil
+}
+
+// writeUnits 生成并写入 .service 和 .timer 单元文件，不调用 systemctl
+func (s systemdScheduler) writeUnits(job scheduledJob) error {
+	service, timer, err := renderSystemdUnits(job)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.root, systemdUnitDir), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.unitPath("service"), []byte(service), 0644); err != nil {
+		return fmt.Errorf("写入 systemd service 失败: %w", err)
+	}
+	if err := os.WriteFile(s.unitPath("timer"), []byte(timer), 0644); err != nil {
+		return fmt.Errorf("写入 systemd timer 失败: %w", err)
+	}
+	return nil
+}
+
+func (s systemdScheduler) remove() error {
+	exec.Command("systemctl", "disable", "--now", systemdUnitName+".timer").Run()
+	for _, suffix := range []string{"timer", "service"} {
+		if err := os.Remove(s.unitPath(suffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	exec.Command("systemctl", "daemon-reload").Run()
+	return nil
+}
+
+func (s systemdScheduler) installed() (bool, string) {
+	_, err := os.Stat(s.unitPath("timer"))
+	return err == nil, s.unitPath("timer")
+}
+
+// renderSystemdUnits 生成 .service 和 .timer 单元文件内容
+// 随机延迟使用 timer 的 RandomizedDelaySec；Persistent=true 使关机期间错过的更新在开机后补执行
+func renderSystemdUnits(job scheduledJob) (service, timer string, err error) {
+	calendars, err := job.schedule.onCalendar()
+	if err != nil {
+		return "", "", err
+	}
+
+	quoted := make([]string, 0, len(job.args)+1)
+	for _, arg := range job.command(true) {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Update GitHub Hosts entries\n")
+	b.WriteString("Wants=network-online.target\n")
+	b.WriteString("After=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=oneshot\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	b.WriteString("StandardOutput=null\n")
+	b.WriteString("StandardError=journal\n")
+	service = b.String()
+
+	b.Reset()
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Scheduled GitHub Hosts update\n\n")
+	b.WriteString("[Timer]\n")
+	for _, calendar := range calendars {
+		fmt.Fprintf(&b, "OnCalendar=%s\n", calendar)
+	}
+	b.WriteString("Persistent=true\n")
+	if job.schedule.Jitter > 0 {
+		fmt.Fprintf(&b, "RandomizedDelaySec=%d\n", job.schedule.Jitter*60)
+	}
+	b.WriteString("AccuracySec=1min\n\n")
+	b.WriteString("[Install]\n")
+	b.WriteString("WantedBy=timers.target\n")
+	timer = b.String()
+	return service, timer, nil
+}
+
+// systemdQuote 为 ExecStart 中的参数加引号，并转义 systemd 的 % 说明符；
+// 引号不能阻止 ExecStart 展开 $VAR 和 ${VAR}，字面的 $ 需要写成 $$
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;$") {
+		return s
+	}
+	return strings.ReplaceAll(strconv.Quote(s), "$", "$$")
+}
+
+// systemdWeekdays OnCalendar 使用的星期名称
+var systemdWeekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// onCalendar 将更新计划转换为 systemd timer 的 OnCalendar 表达式，每个元素对应一行
+func (s ScheduleConfig) onCalendar() ([]string, error) {
+	lines, err := s.cronLines()
+	if err != nil {
+		return nil, err
+	}
+	var calendars []string
+	for _, line := range lines {
+		expr, err := parseCronExpr(line)
+		if err != nil {
+			return nil, err
+		}
+		// systemd 要求日期和星期同时满足，cron 在两者都受限制时满足其一即可，因此分成两行
+		if !expr.day.any && !expr.weekday.any {
+			dayOnly, weekdayOnly := *expr, *expr
+			dayOnly.weekday = cronField{any: true}
+			weekdayOnly.day = cronField{any: true}
+			calendars = append(calendars, dayOnly.calendarSpec(), weekdayOnly.calendarSpec())
+			continue
+		}
+		calendars = append(calendars, expr.calendarSpec())
+	}
+	return calendars, nil
+}
+
+// calendarSpec 生成 "星期 *-月-日 时:分:00" 形式的 OnCalendar 表达式
+func (e cronExpr) calendarSpec() string {
+	var b strings.Builder
+	if !e.weekday.any {
+		names := make([]string, len(e.weekday.values))
+		for i, d := range e.weekday.values {
+			names[i] = systemdWeekdays[d]
+		}
+		b.WriteString(strings.Join(names, ",") + " ")
+	}
+	fmt.Fprintf(&b, "*-%s-%s %s:%s:00",
+		e.month.calendarList(1, 12),
+		e.day.calendarList(1, 31),
+		e.hour.calendarList(0, 23),
+		e.minute.calendarList(0, 59))
+	return b.String()
+}
+
+// calendarList 将字段写成 OnCalendar 的格式：不限制写成 *，等步长序列写成 起始/步长，其他写成逗号列表
+func (f cronField) calendarList(lo, hi int) string {
+	if f.any || len(f.values) == hi-lo+1 {
+		return "*"
+	}
+	if step := f.step(lo, hi); step > 0 {
+		return fmt.Sprintf("%02d/%d", lo, step)
+	}
+	parts := make([]string, len(f.values))
+	for i, v := range f.values {
+		parts[i] = fmt.Sprintf("%02d", v)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// unitValues 返回单元文件中某个键的所有取值
+func unitValues(content, key string) []string {
+	var values []string
+	for _, line := range strings.Split(content, "\n") {
+		if value, ok := strings.CutPrefix(line, key+"="); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func TestSystemdWriteUnits(t *testing.T) {
+	tests := []struct {
+		name        string
+		schedule    ScheduleConfig
+		onCalendar  []string
+		randomDelay []string
+	}{
+		{
+			name:       "整除 60 的间隔",
+			schedule:   ScheduleConfig{Kind: scheduleInterval, Interval: 30},
+			onCalendar: []string{"*-*-* *:00/30:00"},
+		},
+		{
+			name:        "按小时的间隔带随机延迟",
+			schedule:    ScheduleConfig{Kind: scheduleInterval, Interval: 120, Jitter: 10},
+			onCalendar:  []string{"*-*-* 00/2:00:00"},
+			randomDelay: []string{"600"},
+		},
+		{
+			name:       "不能整除 60 的间隔",
+			schedule:   ScheduleConfig{Kind: scheduleInterval, Interval: 90},
+			onCalendar: []string{"*-*-* 00/3:00:00", "*-*-* 01,04,07,10,13,16,19,22:30:00"},
+		},
+		{
+			name:        "每天固定时间",
+			schedule:    ScheduleConfig{Kind: scheduleDaily, At: "04:30", Jitter: 20},
+			onCalendar:  []string{"*-*-* 04:30:00"},
+			randomDelay: []string{"1200"},
+		},
+		{
+			name:       "cron 表达式",
+			schedule:   ScheduleConfig{Kind: scheduleCron, Cron: "0 */3 * * 1-5"},
+			onCalendar: []string{"Mon,Tue,Wed,Thu,Fri *-*-* 00/3:00:00"},
+		},
+		{
+			name:       "cron 同时限制日期和星期",
+			schedule:   ScheduleConfig{Kind: scheduleCron, Cron: "15 4 1 * 1"},
+			onCalendar: []string{"*-*-01 04:15:00", "Mon *-*-* 04:15:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := systemdScheduler{root: t.TempDir()}
+			job := scheduledJob{
+				schedule: tt.schedule,
+				exe:      "/opt/github hosts/github-hosts",
+				args:     []string{"update", "--unattended"},
+			}
+			if err := s.writeUnits(job); err != nil {
+				t.Fatalf("writeUnits() error = %v", err)
+			}
+
+			service, err := os.ReadFile(filepath.Join(s.root, "etc/systemd/system/github-hosts.service"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			timer, err := os.ReadFile(filepath.Join(s.root, "etc/systemd/system/github-hosts.timer"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 随机延迟由 RandomizedDelaySec 实现，命令中不应再带 --jitter
+			wantExec := []string{`"/opt/github hosts/github-hosts" update --unattended`}
+			if got := unitValues(string(service), "ExecStart"); !reflect.DeepEqual(got, wantExec) {
+				t.Errorf("ExecStart = %q, want %q", got, wantExec)
+			}
+			if got := unitValues(string(timer), "OnCalendar"); !reflect.DeepEqual(got, tt.onCalendar) {
+				t.Errorf("OnCalendar = %q, want %q", got, tt.onCalendar)
+			}
+			if got := unitValues(string(timer), "Persistent"); !reflect.DeepEqual(got, []string{"true"}) {
+				t.Errorf("Persistent = %q, want [true]", got)
+			}
+			if got := unitValues(string(timer), "RandomizedDelaySec"); !reflect.DeepEqual(got, tt.randomDelay) {
+				t.Errorf("RandomizedDelaySec = %q, want %q", got, tt.randomDelay)
+			}
+
+			if ok, _ := s.installed(); !ok {
+				t.Error("installed() = false after writeUnits")
+			}
+		})
+	}
+}
+
+func TestOnCalendar(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule ScheduleConfig
+		want     []string
+		wantErr  bool
+	}{
+		{"分钟间隔", intervalSchedule(15), []string{"*-*-* *:00/15:00"}, false},
+		{"45 分钟", intervalSchedule(45), []string{
+			"*-*-* 00/3:00/45:00",
+			"*-*-* 02,05,08,11,14,17,20,23:15:00",
+			"*-*-* 01,04,07,10,13,16,19,22:30:00",
+		}, false},
+		{"24 小时", intervalSchedule(24 * 60), []string{"*-*-* 00:00:00"}, false},
+		{"不能整除一天的间隔", intervalSchedule(100), nil, true},
+		{"每天固定时间", ScheduleConfig{Kind: scheduleDaily, At: "04:30"}, []string{"*-*-* 04:30:00"}, false},
+		{"cron 限制月份和日期", ScheduleConfig{Kind: scheduleCron, Cron: "0 4 1,15 6 *"}, []string{"*-06-01,15 04:00:00"}, false},
+		{"cron 同时限制日期和星期", ScheduleConfig{Kind: scheduleCron, Cron: "15 4 1 * 1"}, []string{"*-*-01 04:15:00", "Mon *-*-* 04:15:00"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.onCalendar()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("onCalendar() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("onCalendar() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"update":         "update",
+		"/usr/bin/gh":    "/usr/bin/gh",
+		"/opt/a b/gh":    `"/opt/a b/gh"`,
+		"100%":           "100%%",
+		`C:\path`:        `"C:\\path"`,
+		"":               `""`,
+		"--base-dir=$HO": `"--base-dir=$$HO"`,
+		"/srv/${HOME}/h": `"/srv/$${HOME}/h"`,
+	}
+	for in, want := range tests {
+		if got := systemdQuote(in); got != want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,28 +1,66 @@
 package main
 
 import (
-	"log"
+	"encoding/json"
+	"log/slog"
+	"net/http"
 	"runtime"
+	"sync"
 	"time"
 )
 
 // App 应用程序结构体
 type App struct {
-	baseDir    string
-	configFile string
-	backupDir  string
-	logDir     string
-	logger     *log.Logger
+	baseDir     string
+	configFile  string
+	backupDir   string
+	logDir      string
+	probeFile   string // 最近一次候选 IP 探测结果
+	daemonFile  string // 守护进程的 PID 和运行状态
+	logMu       sync.Mutex
+	logger      *slog.Logger  // 写入日志文件的 logger，首次写日志时按配置创建
+	logWriter   *rotatingFile // logger 使用的日志文件
+	runID       string        // 正在进行的更新的 ID，写入该次更新的每条日志
+	trigger     string        // 更新的触发方式，为空时按 unattended 判断
+	historyFile string        // 更新历史，每行一条 JSON 记录
+	unattended  bool          // 无人值守模式（定时任务调用），不进行任何交互
+	dohClient   *http.Client  // DoH 请求使用的 HTTP 客户端，为空时使用默认客户端
 }
 
 // Config 配置文件结构体
 type Config struct {
-	UpdateInterval int       `json:"updateInterval"`
-	LastUpdate     time.Time `json:"lastUpdate"`
-	Version        string    `json:"version"`
-	AutoUpdate     bool      `json:"autoUpdate"`
+	SchemaVersion  int              `json:"schemaVersion"` // 配置结构版本，见 configSchemaVersion
+	UpdateInterval int              `json:"updateInterval"`
+	Schedule       *ScheduleConfig  `json:"schedule,omitempty"`  // 更新计划，未配置时按 updateInterval 定时更新
+	Scheduler      string           `json:"scheduler,omitempty"` // 定时任务后端，未配置时自动检测
+	LastUpdate     time.Time        `json:"lastUpdate"`
+	Version        string           `json:"version"`
+	AutoUpdate     bool             `json:"autoUpdate"`
+	Sources        []Source         `json:"sources,omitempty"`      // 按顺序尝试的数据源
+	LastSource     string           `json:"lastSource,omitempty"`   // 最近一次成功获取数据的数据源
+	Resolver       *ResolverConfig  `json:"resolver,omitempty"`     // 解析方式，未配置时从数据源获取
+	Domains        []string         `json:"domains,omitempty"`      // DoH 模式下解析的域名，未配置时使用默认列表
+	Probe          *ProbeConfig     `json:"probe,omitempty"`        // 候选 IP 探测方式，未配置时使用 TLS 握手探测
+	Retention      *RetentionConfig `json:"retention,omitempty"`    // 备份保留策略，未配置时使用默认值
+	Guard          *GuardConfig     `json:"guard,omitempty"`        // 更新后健康检查，未配置时不检查
+	LastRollback   *RollbackRecord  `json:"lastRollback,omitempty"` // 最近一次因健康检查失败而自动回滚的记录
+	Log            *LogConfig       `json:"log,omitempty"`          // 日志格式、级别和轮转策略，未配置时使用默认值
+
+	// extra 本程序不认识的字段（如更新版本写入的配置），保存时原样写回
+	extra map[string]json.RawMessage
+}
+
+// Source hosts 数据源
+type Source struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Token   string            `json:"token,omitempty"`   // 以 Bearer 方式发送的认证令牌
+	Timeout int               `json:"timeout,omitempty"` // 请求超时（秒），0 表示使用默认值
 }
 
+// appVersion 当前程序版本
+const appVersion = "1.0.0"
+
 // LogLevel 定义日志级别
 type LogLevel int
 
@@ -31,10 +69,11 @@ const (
 	SUCCESS
 	WARNING
 	ERROR
+	DEBUG // 只写入日志文件，不输出到控制台
 )
 
 const (
-	MaxMenuOption = 12 // Maximum menu option number
+	MaxMenuOption = 16 // Maximum menu option number
 )
 
 // displayOption 定义菜单选项
@@ -47,7 +86,7 @@ type displayOption struct {
 
 // 系统相关常量
 var (
-	// hostsAPI 定义 API 地址
+	// hostsAPI 定义默认的 API 地址，未配置数据源时使用
 	hostsAPI = "https://github-hosts.tinsfox.com/hosts"
 
 	// hostsFile 根据操作系统定义 hosts 文件路径
@@ -3,9 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"runtime"
-	"strings"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
 )
 
 func (app *App) uninstall() error {
@@ -30,6 +29,11 @@ func (app *App) uninstall() error {
 		return nil
 	}
 
+	return app.removeInstallation()
+}
+
+// removeInstallation 执行卸载：清理 hosts、移除定时任务并删除程序文件
+func (app *App) removeInstallation() error {
 	app.logWithLevelOpt(INFO, false, "开始卸载...")
 
 	// 1. 清理 hosts 文件中的 GitHub 相关记录
@@ -42,14 +46,7 @@ func (app *App) uninstall() error {
 
 	// 2. 移除定时任务
 	app.logWithLevelOpt(INFO, false, "正在移除定时任务...")
-	if runtime.GOOS == "darwin" {
-		exec.Command("launchctl", "bootout", "system/com.github.hosts").Run()
-		os.Remove(darwinPlistPath)
-	} else if runtime.GOOS == "windows" {
-		exec.Command("schtasks", "/delete", "/tn", windowsTaskName, "/f").Run()
-	} else {
-		os.Remove(linuxCronPath)
-	}
+	app.removeCron()
 	app.logWithLevelOpt(SUCCESS, false, "定时任务已移除")
 
 	// 3. 删除程序文件和目录
@@ -72,7 +69,8 @@ func (app *App) uninstall() error {
 		homeDir + "/.github-hosts/logs",    // 日志目录
 	}
 
-	// 删除所有相关目录
+	// 删除所有相关目录，先关闭日志文件
+	app.closeLog()
 	for _, dir := range dirsToRemove {
 		if err := os.RemoveAll(dir); err != nil {
 			app.logWithLevelOpt(WARNING, false, "删除目录失败: %s: %v", dir, err)
@@ -90,49 +88,27 @@ func (app *App) uninstall() error {
 	return nil
 }
 
-// cleanHostsFile 清理 hosts 文件中的 GitHub 相关记录
+// cleanHostsFile 移除 hosts 文件中的 GitHub Hosts 管理区块，其他内容保持不变
 func (app *App) cleanHostsFile() error {
+	unlock, err := app.lockHosts()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// 读取 hosts 文件内容
 	content, err := os.ReadFile(hostsFile)
 	if err != nil {
 		return fmt.Errorf("读取 hosts 文件失败: %w", err)
 	}
 
-	lines := strings.Split(string(content), "\n")
-	var newLines []string
-	var lastLineEmpty bool = true // 用于跟踪上一行是否为空
-
-	// 逐行处理，移除 GitHub 相关记录和多余的空行
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-
-		// 跳过 GitHub 相关记录
-		if strings.Contains(trimmedLine, "github") || strings.Contains(trimmedLine, "githubusercontent") {
-			continue
-		}
-
-		// 处理空行：只有当上一行不是空行时才保留当前空行
-		if trimmedLine == "" {
-			if lastLineEmpty {
-				continue // 跳过连续的空行
-			}
-			lastLineEmpty = true
-		} else {
-			lastLineEmpty = false
-		}
-
-		newLines = append(newLines, line)
-	}
-
-	// 确保文件末尾只有一个换行符
-	for len(newLines) > 0 && strings.TrimSpace(newLines[len(newLines)-1]) == "" {
-		newLines = newLines[:len(newLines)-1]
+	f := hostsfile.Parse(content)
+	if !f.HasBlock() {
+		return nil
 	}
-	newLines = append(newLines, "") // 添加一个空行作为文件结尾
 
 	// 写回文件
-	newContent := strings.Join(newLines, "\n")
-	if err := os.WriteFile(hostsFile, []byte(newContent), 0644); err != nil {
+	if err := writeHostsFile(hostsFile, f.RemoveBlock()); err != nil {
 		return fmt.Errorf("写入 hosts 文件失败: %w", err)
 	}
 
@@ -29,18 +29,18 @@ func (app *App) runDiagnostics() error {
 	app.logWithLevel(INFO, "开始系统诊断...")
 
 	// 1. 检查系统信息
+	system := systemReport()
 	app.logWithLevel(INFO, "系统信息:")
-	app.logWithLevel(INFO, "  • 操作系统: %s", runtime.GOOS)
-	app.logWithLevel(INFO, "  • 架构: %s", runtime.GOARCH)
+	app.logWithLevel(INFO, "  • 操作系统: %s", system.OS)
+	app.logWithLevel(INFO, "  • 架构: %s", system.Arch)
 
 	// 2. 检查目录权限
 	app.logWithLevel(INFO, "检查目录权限...")
-	dirs := []string{app.baseDir, app.backupDir, app.logDir}
-	for _, dir := range dirs {
-		if err := app.checkDirPermissions(dir); err != nil {
-			app.logWithLevel(WARNING, "目录权限问题: %v", err)
+	for _, dir := range app.dirReports() {
+		if dir.Writable {
+			app.logWithLevel(SUCCESS, "目录权限正常: %s", dir.Path)
 		} else {
-			app.logWithLevel(SUCCESS, "目录权限正常: %s", dir)
+			app.logWithLevel(WARNING, "目录权限问题: %s", dir.Error)
 		}
 	}
 
@@ -63,3 +63,24 @@ func (app *App) runDiagnostics() error {
 	app.logWithLevel(SUCCESS, "诊断完成")
 	return nil
 }
+
+// systemReport 返回系统信息
+func systemReport() SystemReport {
+	return SystemReport{OS: runtime.GOOS, Arch: runtime.GOARCH, HostsFile: hostsFile}
+}
+
+// diagnosticsReport 收集系统诊断结果
+func (app *App) diagnosticsReport() *DiagnosticsReport {
+	report := &DiagnosticsReport{
+		reportHeader: newReportHeader(),
+		System:       systemReport(),
+		Directories:  app.dirReports(),
+		Connection:   app.connectionReport(defaultConnTestOptions()),
+	}
+	if config, err := app.loadConfig(); err != nil {
+		report.ConfigError = err.Error()
+	} else {
+		report.Config = newConfigReport(config)
+	}
+	return report
+}
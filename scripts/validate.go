@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TinsFox/github-hosts/scripts/hostsfile"
+)
+
+// minHostEntries 有效 hosts 数据至少应包含的记录数
+const minHostEntries = 10
+
+// maxHostsPayloadSize 下载 hosts 数据的最大字节数
+const maxHostsPayloadSize = 1 << 20
+
+// HostEntry hosts 文件中的一条记录
+type HostEntry = hostsfile.Entry
+
+// defaultDomains 允许写入 hosts 的域名列表
+// 与 worker 提供的域名保持一致（见 src/constants.ts 中的 GITHUB_URLS）
+var defaultDomains = []string{
+	"alive.github.com",
+	"api.github.com",
+	"assets-cdn.github.com",
+	"avatars.githubusercontent.com",
+	"avatars0.githubusercontent.com",
+	"avatars1.githubusercontent.com",
+	"avatars2.githubusercontent.com",
+	"avatars3.githubusercontent.com",
+	"avatars4.githubusercontent.com",
+	"avatars5.githubusercontent.com",
+	"camo.githubusercontent.com",
+	"central.github.com",
+	"cloud.githubusercontent.com",
+	"codeload.github.com",
+	"collector.github.com",
+	"desktop.githubusercontent.com",
+	"favicons.githubusercontent.com",
+	"gist.github.com",
+	"github-cloud.s3.amazonaws.com",
+	"github-com.s3.amazonaws.com",
+	"github-production-release-asset-2e65be.s3.amazonaws.com",
+	"github-production-repository-file-5c1aeb.s3.amazonaws.com",
+	"github-production-user-asset-6210df.s3.amazonaws.com",
+	"github.blog",
+	"github.com",
+	"github.community",
+	"github.githubassets.com",
+	"github.global.ssl.fastly.net",
+	"github.io",
+	"github.map.fastly.net",
+	"githubstatus.com",
+	"live.github.com",
+	"media.githubusercontent.com",
+	"objects.githubusercontent.com",
+	"pipelines.actions.githubusercontent.com",
+	"raw.githubusercontent.com",
+	"user-images.githubusercontent.com",
+	"vscode.dev",
+	"education.github.com",
+	"private-user-images.githubusercontent.com",
+}
+
+// allowedDomains 返回允许写入 hosts 的域名集合
+func (app *App) allowedDomains() map[string]bool {
+	allowed := make(map[string]bool, len(defaultDomains))
+	for _, domain := range defaultDomains {
+		allowed[domain] = true
+	}
+	return allowed
+}
+
+// parseHostsPayload 解析并校验下载的文本格式 hosts 数据
+// 只接受 "IP 域名" 格式的记录、空行和注释；IP 必须是合法的 IPv4/IPv6 地址，
+// 域名必须在允许列表中，且记录数不少于 minHostEntries
+func parseHostsPayload(data []byte, allowed map[string]bool) ([]HostEntry, error) {
+	var entries []HostEntry
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		// 去掉行内注释
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("第 %d 行格式无效: %q", lineNo, scanner.Text())
+		}
+
+		for _, domain := range fields[1:] {
+			entry, err := validateEntry(fields[0], domain, allowed)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行%v", lineNo, err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 hosts 数据失败: %w", err)
+	}
+
+	if err := checkEntryCount(entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseHostsJSON 解析并校验 worker /hosts.json 返回的 [ip, domain] 数组
+func parseHostsJSON(data []byte, allowed map[string]bool) ([]HostEntry, error) {
+	var pairs [][]string
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, fmt.Errorf("JSON 格式无效: %w", err)
+	}
+
+	entries := make([]HostEntry, 0, len(pairs))
+	for i, pair := range pairs {
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("第 %d 条记录格式无效: %q", i+1, pair)
+		}
+		entry, err := validateEntry(pair[0], pair[1], allowed)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 条记录%v", i+1, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := checkEntryCount(entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// validateEntry 校验单条记录的 IP 和域名，返回规范化后的记录
+func validateEntry(rawIP, domain string, allowed map[string]bool) (HostEntry, error) {
+	ip := net.ParseIP(rawIP)
+	if ip == nil {
+		return HostEntry{}, fmt.Errorf(" IP 地址无效: %q", rawIP)
+	}
+	if ip.IsUnspecified() || ip.IsLoopback() {
+		return HostEntry{}, fmt.Errorf(" IP 地址不可用: %s", rawIP)
+	}
+
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if !allowed[domain] {
+		return HostEntry{}, fmt.Errorf("包含未允许的域名: %s", domain)
+	}
+	return HostEntry{IP: ip.String(), Domain: domain}, nil
+}
+
+// checkEntryCount 检查记录数是否达到 minHostEntries
+func checkEntryCount(entries []HostEntry) error {
+	if len(entries) < minHostEntries {
+		return fmt.Errorf("hosts 记录数过少: %d（至少需要 %d 条）", len(entries), minHostEntries)
+	}
+	return nil
+}
+
+// renderHostsBlock 生成管理区块的内容：更新时间、数据源以及按域名排序的记录
+func renderHostsBlock(entries []HostEntry, sourceURL string, updated time.Time) []string {
+	sorted := append([]HostEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Domain < sorted[j].Domain
+	})
+
+	lines := []string{
+		fmt.Sprintf("# (Updated: %s)", updated.Format("2006-01-02 15:04:05")),
+		fmt.Sprintf("# (Source: %s)", sourceURL),
+	}
+	for _, entry := range sorted {
+		lines = append(lines, fmt.Sprintf("%-30s%s", entry.IP, entry.Domain))
+	}
+	return lines
+}